@@ -1,36 +1,45 @@
 package scrubber
 
 import (
+	"fmt"
 	"os"
 )
 
 // checkFn is the function that determines whether a file should be cleaned up or not.
-type checkFn func(file os.FileInfo) bool
+type checkFn = func(file os.FileInfo) bool
 
 // action holds a reference to the current directory and a filesystem handle
 type action struct {
 	dir     *directory
 	fs      Filesystem
-	log     logger
+	log     Logger
 	pretend bool
 }
 
-// performer is the interface that wraps the single method a action implementation has to provide.
-type performer interface {
-	perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error)
+// Action is the interface an action implementation has to provide.
+type Action interface {
+	// Perform handles all files check returns true for and returns the files that were left untouched.
+	Perform(files []os.FileInfo, check func(file os.FileInfo) bool) ([]os.FileInfo, error)
 }
 
 // actionFromConfig returns the action defined in the configuration file.
-func actionFromConfig(c *StrategyConfig, dir *directory, fs Filesystem, log logger, pretend bool) performer {
-	switch c.Action {
-	case ActionTypeDelete:
-		return newDeleteAction(dir, fs, log, pretend)
-	case ActionTypeZip:
-		return newZipAction(dir, fs, log, pretend)
-	default:
-		log.Fatalf("Unknown action type %s", c.Type)
+func actionFromConfig(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (Action, error) {
+	actionsMu.RLock()
+	factory, ok := actions[c.Action]
+	actionsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown action type: %s", c.Action)
 	}
-	return nil
+
+	return factory(ActionContext{
+		Config:     c,
+		Path:       dir.Path,
+		Filesystem: fs,
+		Log:        log,
+		Pretend:    pretend,
+		dir:        dir,
+	})
 }
 
 // removeFile updates the in memory list of all files we're working with.
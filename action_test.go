@@ -0,0 +1,36 @@
+package scrubber
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+)
+
+// runAction runs the strategy c with its action for all files in d, just like a
+// run of the scrubber would, and returns the remaining files and the log output.
+// Strategies without a type select files by their size.
+func runAction(t *testing.T, fs Filesystem, d directory, c StrategyConfig) ([]os.FileInfo, string) {
+	t.Helper()
+
+	if c.Type == "" {
+		c.Type = StrategyTypeSize
+	}
+
+	scanner := newDirectoryScanner(&d, fs)
+	files, err := scanner.getFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	p, err := strategyFromConfig(&c, &d, fs, log.New(&out, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining, err := p.process(scanner.filterFiles(files))
+	if err != nil {
+		t.Errorf("expected no error from process, got %v\n", err)
+	}
+	return remaining, out.String()
+}
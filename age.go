@@ -20,54 +20,83 @@ var durationUnits = map[string]time.Duration{
 // ageStrategy represents the action of deleting files past a certain age.
 type ageStrategy struct {
 	Strategy
+	fs    Filesystem
 	limit time.Duration
 }
 
 // newAgeStrategy returns a new ageStrategy.
-func newAgeStrategy(c *StrategyConfig, dir *directory, action performer, log logger) *ageStrategy {
-	return &ageStrategy{Strategy{c, dir, action, log}, 0}
+func newAgeStrategy(c *StrategyConfig, dir *directory, fs Filesystem, action Action, log Logger) *ageStrategy {
+	return &ageStrategy{Strategy{c, dir, action, log}, fs, 0}
 }
 
-// process deletes all files past a certain age.
-func (s ageStrategy) process(files []os.FileInfo) ([]os.FileInfo, error) {
+// Select selects files past a certain age.
+func (s ageStrategy) Select(files []os.FileInfo) (checkFn, error) {
 	err := s.unmarshalText([]byte(s.c.Limit))
 	if err != nil {
 		return nil, err
 	}
 
+	fileTime, err := s.ageTime()
+	if err != nil {
+		return nil, err
+	}
+
 	deadline := time.Now().Add(-1 * s.limit)
 
-	files, err = s.action.perform(files, func(file os.FileInfo) bool {
-		return file.ModTime().Before(deadline)
-	})
+	return func(file os.FileInfo) bool {
+		t, err := fileTime(file)
+		if err != nil {
+			s.log.Printf("[Age] Skipping file %s: %s", file.Name(), err)
+			return false
+		}
+		return t.Before(deadline)
+	}, nil
+}
 
-	return files, err
+// ageTime returns the fileTimeFn used to determine the age of a file.
+func (s ageStrategy) ageTime() (fileTimeFn, error) {
+	if s.c.AgeBasis == "" || s.c.AgeBasis == AgeBasisModTime {
+		return s.fileTime()
+	}
+	if s.c.NameTimePattern != "" || s.dir.NameTimePattern != "" {
+		return nil, fmt.Errorf("age_basis and name_time_pattern cannot be combined")
+	}
+	return newBasisTimeFn(s.c.AgeBasis, s.fs, s.dir.Path)
 }
 
 // unmarshalText turns a string representation of a duration into a time.Duration
 func (s *ageStrategy) unmarshalText(text []byte) error {
+	limit, err := parseAge(string(text))
+	if err != nil {
+		return err
+	}
+
+	s.limit = limit
+
+	return nil
+}
+
+// parseAge turns a string representation of an age like 1d 12h into a time.Duration.
+func parseAge(limit string) (time.Duration, error) {
 	var total time.Duration
-	var limit = string(text)
 
 	if limit == "" {
-		return fmt.Errorf("limit cannot be an empty string")
+		return 0, fmt.Errorf("limit cannot be an empty string")
 	}
 
 	for _, part := range strings.Fields(limit) {
 		quantifier, err := strconv.Atoi(part[:len(part)-1])
 		if err != nil || quantifier < 0 {
-			return fmt.Errorf("invalid limit quantifier %q passed (%s)", quantifier, err)
+			return 0, fmt.Errorf("invalid limit quantifier %q passed (%s)", quantifier, err)
 		}
 
 		duration, ok := durationUnits[part[len(part)-1:]]
 		if !ok {
-			return fmt.Errorf("unknown limit unit %s passed to AgeStrategy", part[:len(part)-1])
+			return 0, fmt.Errorf("unknown limit unit %s passed to AgeStrategy", part[:len(part)-1])
 		}
 
 		total = total + (time.Duration(quantifier) * duration)
 	}
 
-	s.limit = total
-
-	return nil
+	return total, nil
 }
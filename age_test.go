@@ -24,9 +24,9 @@ func TestAge(t *testing.T) {
 	logger := log.New(ioutil.Discard, "", 0)
 
 	a := newDeleteAction(&d, fs, logger, false)
-	s := newAgeStrategy(&c, &d, a, logger)
+	s := newAgeStrategy(&c, &d, fs, a, logger)
 
-	_, err := s.process(files)
+	_, err := selectorStrategy{s, a}.process(files)
 	if err != nil {
 		t.Errorf("expected no error, got %v\n", err)
 	}
@@ -54,9 +54,9 @@ func TestAgeKeepLatest(t *testing.T) {
 	logger := log.New(ioutil.Discard, "", 0)
 
 	a := newDeleteAction(&d, fs, logger, false)
-	s := newAgeStrategy(&c, &d, a, logger)
+	s := newAgeStrategy(&c, &d, fs, a, logger)
 
-	_, err := s.process(files)
+	_, err := selectorStrategy{s, a}.process(files)
 	if err != nil {
 		t.Errorf("expected no error, got %v\n", err)
 	}
@@ -84,7 +84,7 @@ func TestAgeLimitParser(t *testing.T) {
 		{"1y 1w 2d 1h 0m", 8977 * time.Hour},
 	}
 
-	s := newAgeStrategy(nil, &directory{Path: "test"}, nil, nil)
+	s := newAgeStrategy(nil, &directory{Path: "test"}, nil, nil, nil)
 
 	for _, table := range testTimes {
 		err := s.unmarshalText([]byte(table.test))
@@ -118,7 +118,7 @@ func TestInvalidAgeLimitParser(t *testing.T) {
 		{"/"},
 	}
 
-	s := newAgeStrategy(nil, &directory{Path: "test"}, nil, nil)
+	s := newAgeStrategy(nil, &directory{Path: "test"}, nil, nil, nil)
 
 	for _, table := range testTimes {
 		err := s.unmarshalText([]byte(table.test))
@@ -127,3 +127,97 @@ func TestInvalidAgeLimitParser(t *testing.T) {
 		}
 	}
 }
+
+// TestAgeNameTime tests that the age is derived from the file name and unmatched files are skipped.
+func TestAgeNameTime(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	lastYear := time.Now().AddDate(-1, 0, 0).Format("2006-01-02")
+
+	files := []os.FileInfo{
+		mockedFileInfo{name: "app-" + yesterday + ".log", modTime: time.Now().AddDate(-1, 0, 0)},
+		mockedFileInfo{name: "app-" + lastYear + ".log", modTime: time.Now()},
+		mockedFileInfo{name: "unrelated.log", modTime: time.Now().AddDate(-1, 0, 0)},
+	}
+
+	fs := &mockedFs{}
+
+	c := StrategyConfig{Type: StrategyTypeAge, Limit: "7d", Action: ActionTypeDelete}
+	d := directory{Path: testPath, NameTimePattern: `app-(\d{4}-\d{2}-\d{2})\.log`, NameTimeLayout: "2006-01-02"}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newAgeStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 1 || fs.deleted[0] != testPath+"/app-"+lastYear+".log" {
+		t.Errorf("expected only \"app-%s.log\" to be removed got %v.\n", lastYear, fs.deleted)
+	}
+}
+
+// TestAgeBasis tests that the age can be measured from the access time instead of the modification time.
+func TestAgeBasis(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "recentlyread", modTime: time.Now().AddDate(-1, 0, 0), accessTime: time.Now()},
+		mockedFileInfo{name: "neverread", modTime: time.Now(), accessTime: time.Now().AddDate(0, 0, -30)},
+		mockedFileInfo{name: "noatime", modTime: time.Now().AddDate(-1, 0, 0)},
+	}
+
+	fs := &mockedFs{}
+
+	c := StrategyConfig{Type: StrategyTypeAge, Limit: "7d", Action: ActionTypeDelete, AgeBasis: AgeBasisAccessTime}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newAgeStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 1 || fs.deleted[0] != testPath+"/neverread" {
+		t.Errorf("expected only \"neverread\" to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestInvalidAgeBasis tests that unknown age bases are being rejected.
+func TestInvalidAgeBasis(t *testing.T) {
+	c := StrategyConfig{Type: StrategyTypeAge, Limit: "7d", Action: ActionTypeDelete, AgeBasis: "xtime"}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	fs := &mockedFs{}
+	s := newAgeStrategy(&c, &d, fs, newDeleteAction(&d, fs, logger, false), logger)
+
+	_, err := s.Select([]os.FileInfo{mockedFileInfo{name: "file"}})
+	if err == nil {
+		t.Errorf("expected an error for an unknown age_basis")
+	}
+}
+
+// TestAgeBasisNameTime tests that an age_basis cannot be combined with the name_time_pattern of the directory.
+func TestAgeBasisNameTime(t *testing.T) {
+	c := StrategyConfig{Type: StrategyTypeAge, Limit: "7d", Action: ActionTypeDelete, AgeBasis: AgeBasisAccessTime}
+	d := directory{Path: testPath, NameTimePattern: `(\d{8})`, NameTimeLayout: "20060102"}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	fs := &mockedFs{}
+	s := newAgeStrategy(&c, &d, fs, newDeleteAction(&d, fs, logger, false), logger)
+
+	_, err := s.Select([]os.FileInfo{mockedFileInfo{name: "app-20230101.log"}})
+	if err == nil {
+		t.Errorf("expected an error for an age_basis combined with the name_time_pattern of the directory")
+	}
+	if len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be removed, got %v", fs.deleted)
+	}
+}
@@ -0,0 +1,370 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// pathFileExt is appended to the name of an archive for the file that stores
+// the absolute path of the original file if the archive format has no room for it.
+const pathFileExt = ".path"
+
+// errArchiveSkipped is returned by an archiveFn if the archive already exists and the file is skipped.
+var errArchiveSkipped = errors.New("archive already exists")
+
+// archiveFn is the function that writes the archive of a single file.
+type archiveFn func(filePath string) error
+
+// performArchive hands all files check returns true for to archive and removes
+// the original file once its archive has been written successfully. name is the
+// lowercase name of the archive format used in log messages.
+func (a action) performArchive(files []os.FileInfo, check checkFn, name string, archive archiveFn) ([]os.FileInfo, error) {
+	tag := strings.ToUpper(name)
+
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+
+		if check(file) {
+			if a.pretend {
+				a.log.Printf("[%s] PRETEND: Would %s file %s", tag, name, filename)
+				continue
+			}
+
+			a.log.Printf("[%s] Compressing file %s", tag, filename)
+
+			err := archive(filename)
+			if errors.Is(err, errArchiveSkipped) {
+				a.log.Printf("[%s] Skipping file %s, its archive already exists", tag, filename)
+				newFiles = append(newFiles, file)
+				continue
+			}
+			if err != nil {
+				return files, err
+			}
+			err = a.fs.Remove(filename)
+			if err != nil {
+				a.log.Printf("[%s] ERROR: Failed to delete original file %s: %s", tag, filename, err)
+				continue
+			}
+		} else {
+			a.log.Printf("[%s] No action is needed for file %s", tag, filename)
+			newFiles = append(newFiles, file)
+		}
+	}
+	return newFiles, nil
+}
+
+// archiveOptions holds the options of all actions that compress single files.
+type archiveOptions struct {
+	collision     CollisionPolicy
+	preserveMode  bool
+	preserveOwner bool
+	recipients    []age.Recipient
+	// pathFile writes the absolute path of the original file next to the archive, see writePathFile.
+	pathFile bool
+}
+
+// newArchiveOptions returns the archive options of c. Existing archives are
+// never replaced, so CollisionOverwrite is rejected.
+func newArchiveOptions(c *StrategyConfig) (archiveOptions, error) {
+	if c.Collision == CollisionOverwrite {
+		return archiveOptions{}, fmt.Errorf("existing archives cannot be overwritten, expected suffix, skip or fail")
+	}
+	collision, err := validateCollision(c.Collision)
+	if err != nil {
+		return archiveOptions{}, err
+	}
+	if c.PreserveOwner && !ownershipSupported {
+		return archiveOptions{}, fmt.Errorf("preserve_owner is not supported on this platform")
+	}
+	recipients, err := newRecipients(c)
+	if err != nil {
+		return archiveOptions{}, err
+	}
+
+	return archiveOptions{collision: collision, preserveMode: c.PreserveMode, preserveOwner: c.PreserveOwner, recipients: recipients}, nil
+}
+
+// compressFn is the function that compresses src into dst.
+type compressFn func(dst io.Writer, src io.Reader, info os.FileInfo) error
+
+// decompressFn opens the single entry of an archive for reading.
+type decompressFn func(archive io.Reader) (io.ReadCloser, error)
+
+// compressFile compresses filePath into a new file with the extension ext
+// appended. The archive is written to a temporary file and read back using
+// decompress and compared with the original before it is renamed into place,
+// a partial or broken archive is removed. Existing archives are handled by the
+// collision policy and never replaced, not even if they are created while the
+// archive is being written. If recipients are configured, the archive
+// is encrypted and .age is appended to its name. The modification time of the
+// original file is set on the result, its mode and ownership if configured.
+// If a path file is requested and cannot be written, the archive is removed again.
+func (a action) compressFile(filePath, ext string, opts archiveOptions, compress compressFn, decompress decompressFn) error {
+	info, err := a.fs.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	file, err := a.fs.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	if len(opts.recipients) > 0 {
+		ext += encryptedExt
+	}
+
+	archiveName, skip, err := resolveCollision(a.fs, filePath+ext, opts.collision)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return errArchiveSkipped
+	}
+
+	tmpName := tempPath(archiveName)
+	archiveFile, err := a.fs.Create(tmpName)
+	if err != nil {
+		return fmt.Errorf("failed to create %s file: %v", ext, err)
+	}
+
+	var dst io.Writer = archiveFile
+	var encrypter io.WriteCloser
+	var identity age.Identity
+	if len(opts.recipients) > 0 {
+		encrypter, identity, err = encryptTo(archiveFile, opts.recipients)
+		if err != nil {
+			archiveFile.Close()
+			a.fs.Remove(tmpName)
+			return err
+		}
+		dst = encrypter
+	}
+
+	source := newChecksumWriter()
+	err = compress(dst, io.TeeReader(file, source), info)
+	if err == nil && encrypter != nil {
+		err = encrypter.Close()
+	}
+	if err != nil {
+		archiveFile.Close()
+		a.fs.Remove(tmpName)
+		return fmt.Errorf("failed to write %s file: %v", ext, err)
+	}
+
+	err = syncClose(archiveFile)
+	if err == nil {
+		err = a.verifyArchive(tmpName, filePath, info, source.checksum(), identity, decompress)
+	}
+	if err == nil {
+		err = a.preserveMetadata(tmpName, info, opts)
+	}
+	if err == nil {
+		archiveName, skip, err = renameNoReplace(a.fs, tmpName, filePath+ext, opts.collision)
+	}
+	if err == nil && skip {
+		err = errArchiveSkipped
+	}
+	if err == nil && opts.pathFile {
+		err = a.writePathFile(archiveName, filePath, info, opts)
+		if err != nil {
+			a.fs.Remove(archiveName)
+		}
+	}
+	if err != nil {
+		a.fs.Remove(tmpName)
+		return err
+	}
+
+	return nil
+}
+
+// writePathFile stores the absolute path of filePath in a file next to the archive
+// archiveName, like app.log.xz.path. For encrypted archives, the path file is
+// encrypted as well and named like app.log.xz.path.age, so it decrypts to the
+// former. It gets the same metadata as the archive. An existing path file of a
+// removed archive is replaced.
+func (a action) writePathFile(archiveName, filePath string, info os.FileInfo, opts archiveOptions) error {
+	name := archiveName + pathFileExt
+	if len(opts.recipients) > 0 {
+		name = strings.TrimSuffix(archiveName, encryptedExt) + pathFileExt + encryptedExt
+	}
+
+	tmpName := tempPath(name)
+	out, err := a.fs.Create(tmpName)
+	if err != nil {
+		return fmt.Errorf("failed to create path file: %v", err)
+	}
+
+	var w io.Writer = out
+	var encrypter io.WriteCloser
+	if len(opts.recipients) > 0 {
+		encrypter, _, err = encryptTo(out, opts.recipients)
+		if err != nil {
+			out.Close()
+			a.fs.Remove(tmpName)
+			return err
+		}
+		w = encrypter
+	}
+
+	_, err = io.WriteString(w, absPath(filePath)+"\n")
+	if err == nil && encrypter != nil {
+		err = encrypter.Close()
+	}
+	if err == nil {
+		err = syncClose(out)
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = a.preserveMetadata(tmpName, info, opts)
+	}
+	if err == nil {
+		err = a.fs.Rename(tmpName, name)
+	}
+	if err != nil {
+		a.fs.Remove(tmpName)
+		return fmt.Errorf("failed to write path file: %v", err)
+	}
+
+	return nil
+}
+
+// preserveMetadata copies the modification time and, if configured, the ownership and mode of info to name.
+func (a action) preserveMetadata(name string, info os.FileInfo, opts archiveOptions) error {
+	if opts.preserveOwner {
+		uid, gid, ok := fileOwner(info)
+		if !ok {
+			return fmt.Errorf("failed to read the owner of %s", info.Name())
+		}
+		err := a.fs.Chown(name, uid, gid)
+		if err != nil {
+			return fmt.Errorf("failed to preserve ownership: %v", err)
+		}
+	}
+
+	if opts.preserveMode {
+		err := a.fs.Chmod(name, info.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("failed to preserve mode: %v", err)
+		}
+	}
+
+	return a.fs.Chtimes(name, info.ModTime(), info.ModTime())
+}
+
+// absPath returns the absolute path of path, or path itself if it cannot be determined.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// verifyArchive decompresses archiveName and compares its content with the
+// checksum of the original file. Encrypted archives are decrypted using identity
+// first. It also makes sure the original file has not been changed while it was
+// compressed.
+func (a action) verifyArchive(archiveName, filePath string, info os.FileInfo, expected checksum, identity age.Identity, decompress decompressFn) error {
+	if expected.size != info.Size() {
+		return fmt.Errorf("file %s changed while it was compressed", filePath)
+	}
+
+	archiveFile, err := a.fs.Open(archiveName)
+	if err != nil {
+		return fmt.Errorf("failed to open archive for verification: %v", err)
+	}
+	defer archiveFile.Close()
+
+	var archive io.Reader = archiveFile
+	if identity != nil {
+		archive, err = age.Decrypt(archiveFile, identity)
+		if err != nil {
+			return fmt.Errorf("failed to verify archive %s: %v", archiveName, err)
+		}
+	}
+
+	r, err := decompress(archive)
+	if err != nil {
+		return fmt.Errorf("failed to verify archive %s: %v", archiveName, err)
+	}
+	actual, err := checksumOf(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("failed to verify archive %s: %v", archiveName, err)
+	}
+	if actual != expected {
+		return fmt.Errorf("failed to verify archive %s: expected %d bytes with CRC32 %08x, got %d bytes with CRC32 %08x",
+			archiveName, expected.size, expected.crc, actual.size, actual.crc)
+	}
+
+	current, err := a.fs.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+	if current.Size() != info.Size() || !current.ModTime().Equal(info.ModTime()) {
+		return fmt.Errorf("file %s changed while it was compressed", filePath)
+	}
+
+	return nil
+}
+
+// checksum identifies the content of a file by its size and CRC32.
+type checksum struct {
+	size int64
+	crc  uint32
+}
+
+// checksumWriter computes the checksum of everything written to it.
+type checksumWriter struct {
+	size int64
+	crc  hash.Hash32
+}
+
+// newChecksumWriter returns a pointer to an empty checksumWriter.
+func newChecksumWriter() *checksumWriter {
+	return &checksumWriter{crc: crc32.NewIEEE()}
+}
+
+// Write adds p to the checksum.
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return w.crc.Write(p)
+}
+
+// checksum returns the checksum of everything written so far.
+func (w *checksumWriter) checksum() checksum {
+	return checksum{w.size, w.crc.Sum32()}
+}
+
+// checksumOf reads r completely and returns its checksum.
+func checksumOf(r io.Reader) (checksum, error) {
+	w := newChecksumWriter()
+	_, err := io.Copy(w, r)
+	return w.checksum(), err
+}
+
+// closeAll closes all closers in order and returns the first error.
+func closeAll(closers ...interface{ Close() error }) error {
+	var first error
+	for _, c := range closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = fmt.Errorf("failed to close archive: %v", err)
+		}
+	}
+	return first
+}
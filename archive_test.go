@@ -0,0 +1,207 @@
+package scrubber
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCompressFileVerify tests that a broken archive is removed and the original is kept.
+func TestCompressFileVerify(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "line 1\nline 2\n", time.Now())
+
+	a := action{&directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false}
+
+	copyFn := func(dst io.Writer, src io.Reader, info os.FileInfo) error {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	brokenFn := func(archive io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("line 1\n")), nil
+	}
+
+	err := a.compressFile(path, ".broken", archiveOptions{collision: CollisionSuffix}, copyFn, brokenFn)
+	if err == nil || !strings.Contains(err.Error(), "failed to verify") {
+		t.Errorf("expected a verification error, got %v", err)
+	}
+	assertContent(t, path, "line 1\nline 2\n")
+	assertMissing(t, path+".broken")
+
+	err = a.compressFile(path, ".copy", archiveOptions{collision: CollisionSuffix}, copyFn, func(archive io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(archive), nil
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	assertContent(t, path+".copy", "line 1\nline 2\n")
+}
+
+// TestBundleVerify tests that a bundle is only kept if all added files can be read back.
+func TestBundleVerify(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "content", time.Now())
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := newBundleAction(&StrategyConfig{Archive: "logs.tar"}, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(dir, "logs.tar")
+	err = a.write(&bundle{path: archive, files: []os.FileInfo{info}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.verify(archive, "tar", nil, map[string]checksum{"app.log": {size: 7}})
+	if err == nil {
+		t.Error("expected an error for a wrong checksum")
+	}
+	err = a.verify(archive, "tar", nil, map[string]checksum{"other.log": {}})
+	if err == nil {
+		t.Error("expected an error for a missing entry")
+	}
+}
+
+// TestArchiveMetadata tests that archives keep the modification time, mode and original path of a file.
+func TestArchiveMetadata(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	modTime := time.Now().AddDate(0, 0, -3).Truncate(time.Second)
+	logger := log.New(ioutil.Discard, "", 0)
+	c := StrategyConfig{PreserveMode: true, PreserveOwner: ownershipSupported}
+
+	writeTestFile(t, path, "content", modTime)
+	err := os.Chmod(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := newGzipAction(&c, &directory{Path: dir}, OSFilesystem{}, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gz.gzip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gzr.Comment != path {
+		t.Errorf("expected the gzip comment to be %s, got %q", path, gzr.Comment)
+	}
+	assertMetadata(t, path+".gz", modTime, 0600)
+
+	zp, err := newZipAction(&c, &directory{Path: dir}, OSFilesystem{}, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zp.zip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(path + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if zr.Comment != path {
+		t.Errorf("expected the zip comment to be %s, got %q", path, zr.Comment)
+	}
+	assertMetadata(t, path+".zip", modTime, 0600)
+}
+
+// TestBundleMetadata tests that bundles record the original paths and the newest modification time.
+func TestBundleMetadata(t *testing.T) {
+	dir := t.TempDir()
+	newest := time.Now().AddDate(0, 0, -1).Truncate(time.Second)
+	writeTestFile(t, filepath.Join(dir, "a.log"), "a", newest.AddDate(0, 0, -1))
+	writeTestFile(t, filepath.Join(dir, "b.log"), "b", newest)
+
+	var files []os.FileInfo
+	for _, name := range []string{"a.log", "b.log"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, info)
+	}
+
+	a, err := newBundleAction(&StrategyConfig{Archive: "logs.tar"}, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "logs.tar")
+	err = a.write(&bundle{path: archive, files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := filepath.Join(dir, header.Name)
+		if header.PAXRecords[tarPathRecord] != expected {
+			t.Errorf("expected %s to record %s, got %q", header.Name, expected, header.PAXRecords[tarPathRecord])
+		}
+	}
+
+	info, err := os.Stat(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(newest) {
+		t.Errorf("expected the bundle to have mtime %s, got %s", newest, info.ModTime())
+	}
+}
+
+// assertMetadata checks the modification time and mode of a file.
+func assertMetadata(t *testing.T, path string, modTime time.Time, mode os.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected %s to have mtime %s, got %s", path, modTime, info.ModTime())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != mode {
+		t.Errorf("expected %s to have mode %s, got %s", path, mode, info.Mode().Perm())
+	}
+}
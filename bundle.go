@@ -0,0 +1,632 @@
+package scrubber
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"filippo.io/age"
+)
+
+// bundleBuckets maps a bucket name to the function that returns the start of the bucket a point in time belongs to.
+var bundleBuckets = map[string]func(t time.Time) time.Time{
+	"day": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	},
+	"week": func(t time.Time) time.Time {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	},
+	"month": func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	},
+}
+
+// bundleData is passed to the archive name template.
+type bundleData struct {
+	// Time is the start of the bucket or the time of the current run if no bucket is used.
+	Time time.Time
+	// DirName is the name of the directory that is being scrubbed.
+	DirName string
+}
+
+// Date formats the time of the bundle using a Go time layout.
+func (d bundleData) Date(layout string) string {
+	return d.Time.Format(layout)
+}
+
+// bundleAction represents the action of collecting old files in a single archive.
+type bundleAction struct {
+	action
+	name       *template.Template
+	bucket     func(t time.Time) time.Time
+	recipients []age.Recipient
+}
+
+// bundle is a single archive and all files that are added to it.
+type bundle struct {
+	path   string
+	format string
+	files  []os.FileInfo
+	// name is the rendered archive path collisions of encrypted archives are resolved against.
+	name string
+}
+
+// newBundleAction returns a pointer to a bundleAction.
+func newBundleAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*bundleAction, error) {
+	if c.Archive == "" {
+		return nil, fmt.Errorf("bundle action requires an archive name")
+	}
+	if bundleFormat(c.Archive) == "" {
+		return nil, fmt.Errorf("unsupported archive %s, expected a .tar.gz, .tgz, .tar or .zip file", c.Archive)
+	}
+
+	name, err := template.New("archive").Option("missingkey=error").Parse(c.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive name template: %s", err)
+	}
+
+	var bucket func(t time.Time) time.Time
+	if c.Bucket != "" {
+		var ok bool
+		bucket, ok = bundleBuckets[c.Bucket]
+		if !ok {
+			return nil, fmt.Errorf("unknown bucket %q, expected day, week or month", c.Bucket)
+		}
+	}
+
+	recipients, err := newRecipients(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) > 0 && bundleFormat(c.Archive) == "zip" {
+		return nil, fmt.Errorf("zip archives cannot be encrypted, use a .tar.gz, .tgz or .tar file instead")
+	}
+
+	return &bundleAction{action{dir, fs, log, pretend}, name, bucket, recipients}, nil
+}
+
+// bundleFormat returns the archive format of a file name.
+func bundleFormat(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// Perform collects all files that are past a certain age or certain size in archives.
+func (a bundleAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	now := time.Now()
+
+	var newFiles []os.FileInfo
+	var bundles []*bundle
+	byPath := make(map[string]*bundle)
+	for _, file := range files {
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[BUNDLE] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		t := now
+		if a.bucket != nil {
+			t = a.bucket(file.ModTime())
+		}
+		path, err := a.archivePath(t)
+		if err != nil {
+			return files, err
+		}
+		if path == filename || (len(a.recipients) > 0 && isEncryptedBundle(filename, path)) {
+			a.log.Printf("[BUNDLE] Skipping archive %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		b, ok := byPath[path]
+		if !ok {
+			b = &bundle{path: path, format: bundleFormat(path)}
+			byPath[path] = b
+			bundles = append(bundles, b)
+		}
+		b.files = append(b.files, file)
+	}
+
+	for _, b := range bundles {
+		if len(a.recipients) > 0 {
+			// Encrypted archives cannot be read back without the private keys,
+			// so every run writes a new archive instead of extending one.
+			b.name = b.path + encryptedExt
+			path, _, err := resolveCollision(a.fs, b.name, CollisionSuffix)
+			if err != nil {
+				return files, err
+			}
+			b.path = path
+		}
+
+		if a.pretend {
+			for _, file := range b.files {
+				a.log.Printf("[BUNDLE] PRETEND: Would add file %s to %s", a.fs.FullPath(file, a.dir.Path), b.path)
+			}
+			continue
+		}
+
+		a.log.Printf("[BUNDLE] Adding %d files to %s", len(b.files), b.path)
+
+		err := a.write(b)
+		if err != nil {
+			return files, err
+		}
+
+		for _, file := range b.files {
+			filename := a.fs.FullPath(file, a.dir.Path)
+			err = a.fs.Remove(filename)
+			if err != nil {
+				a.log.Printf("[BUNDLE] ERROR: Failed to delete original file %s: %s", filename, err)
+			}
+		}
+	}
+
+	return newFiles, nil
+}
+
+// archivePath renders the archive name template for t. Relative names are
+// resolved against the directory that is being scrubbed.
+func (a bundleAction) archivePath(t time.Time) (string, error) {
+	var name strings.Builder
+	err := a.name.Execute(&name, bundleData{Time: t, DirName: filepath.Base(a.dir.Path)})
+	if err != nil {
+		return "", fmt.Errorf("failed to render archive name: %s", err)
+	}
+
+	path := name.String()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a.dir.Path, path)
+	}
+
+	return path, nil
+}
+
+// isEncryptedBundle reports whether filename is an encrypted archive written for
+// the archive path, either path.age or a numbered variant like name.tar.1.gz.age.
+func isEncryptedBundle(filename, path string) bool {
+	name, ok := strings.CutSuffix(filename, encryptedExt)
+	if !ok {
+		return false
+	}
+	if name == path {
+		return true
+	}
+
+	ext := filepath.Ext(path)
+	n, ok := strings.CutPrefix(name, strings.TrimSuffix(path, ext)+".")
+	if !ok {
+		return false
+	}
+	n, ok = strings.CutSuffix(n, ext)
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(n)
+	return err == nil
+}
+
+// write adds all files of b to its archive. Entries of an already existing
+// archive are carried over. The archive is written to a temporary file first
+// and only replaces the existing archive once it has been written completely
+// and all added files have been read back successfully. Encrypted archives are
+// verified using an additional identity that is only kept in memory. The
+// modification time of the archive is set to the newest file it contains.
+func (a bundleAction) write(b *bundle) error {
+	var modTime time.Time
+	existing, err := a.fs.Stat(b.path)
+	if err == nil {
+		modTime = existing.ModTime()
+	} else if os.IsNotExist(err) {
+		existing = nil
+	} else {
+		return fmt.Errorf("failed to stat archive: %v", err)
+	}
+	for _, file := range b.files {
+		if file.ModTime().After(modTime) {
+			modTime = file.ModTime()
+		}
+	}
+
+	tmpPath := tempPath(b.path)
+	out, err := a.fs.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+
+	var w io.Writer = out
+	var encrypter io.WriteCloser
+	var identity age.Identity
+	if len(a.recipients) > 0 {
+		encrypter, identity, err = encryptTo(out, a.recipients)
+		if err != nil {
+			out.Close()
+			a.fs.Remove(tmpPath)
+			return err
+		}
+		w = encrypter
+	}
+
+	added, err := a.fill(w, b)
+	if err == nil && encrypter != nil {
+		err = encrypter.Close()
+	}
+	if err == nil {
+		err = syncClose(out)
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = a.verify(tmpPath, b.format, identity, added)
+	}
+	if err == nil {
+		err = a.fs.Chtimes(tmpPath, modTime, modTime)
+	}
+	if err == nil {
+		err = a.replace(tmpPath, b, existing)
+	}
+	if err != nil {
+		a.fs.Remove(tmpPath)
+		return fmt.Errorf("failed to write archive %s: %v", b.path, err)
+	}
+
+	return nil
+}
+
+// replace renames the temporary archive to the archive of b. An archive is only
+// replaced if it is the one whose entries have been carried over, so archives
+// that are created or changed by someone else in the meantime are kept.
+func (a bundleAction) replace(tmpPath string, b *bundle, existing os.FileInfo) error {
+	if b.name != "" {
+		path, _, err := renameNoReplace(a.fs, tmpPath, b.name, CollisionSuffix)
+		if err == nil && path != b.path {
+			a.log.Printf("[BUNDLE] Wrote archive %s instead, %s has been created in the meantime", path, b.path)
+		}
+		return err
+	}
+
+	if existing == nil {
+		_, _, err := renameNoReplace(a.fs, tmpPath, b.path, CollisionFail)
+		return err
+	}
+
+	info, err := a.fs.Stat(b.path)
+	if err != nil {
+		return err
+	}
+	if info.Size() != existing.Size() || !info.ModTime().Equal(existing.ModTime()) {
+		return fmt.Errorf("archive has been changed in the meantime")
+	}
+	return a.fs.Rename(tmpPath, b.path)
+}
+
+// fill writes the existing entries and all files of b to out. Existing entries
+// are never replaced, files whose name is already taken are added with a number
+// like app.1.log instead. It returns the checksums of the added entries.
+func (a bundleAction) fill(out io.Writer, b *bundle) (map[string]checksum, error) {
+	w := newBundleWriter(b.format, out)
+
+	added := make(map[string]checksum, len(b.files))
+	names := make(map[string]bool)
+
+	existing, err := a.fs.Open(b.path)
+	if err == nil {
+		names, err = w.copyExisting(existing)
+		existing.Close()
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to copy existing archive: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		w.Close()
+		return nil, err
+	}
+
+	for _, file := range b.files {
+		name := file.Name()
+		for i := 1; names[name]; i++ {
+			name = collisionName(file.Name(), i)
+		}
+		if name != file.Name() {
+			a.log.Printf("[BUNDLE] Adding file %s as %s, %s already contains %s", a.fs.FullPath(file, a.dir.Path), name, b.path, file.Name())
+		}
+
+		sum, err := a.add(w, file, name)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		names[name] = true
+		added[name] = sum
+	}
+
+	return added, w.Close()
+}
+
+// add writes a single file to the archive as entry name and returns the checksum of its content.
+func (a bundleAction) add(w bundleWriter, file os.FileInfo, name string) (checksum, error) {
+	filename := a.fs.FullPath(file, a.dir.Path)
+
+	info, err := a.fs.Stat(filename)
+	if err != nil {
+		return checksum{}, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	f, err := a.fs.Open(filename)
+	if err != nil {
+		return checksum{}, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	sum := newChecksumWriter()
+	err = w.add(info, name, absPath(filename), io.TeeReader(f, sum))
+	if err != nil {
+		return checksum{}, err
+	}
+	if sum.size != info.Size() {
+		return checksum{}, fmt.Errorf("file %s changed while it was added", filename)
+	}
+
+	return sum.checksum(), nil
+}
+
+// verify reads all added entries back from the archive at path and compares them with
+// their checksums. Encrypted archives are decrypted using identity first.
+func (a bundleAction) verify(path, format string, identity age.Identity, added map[string]checksum) error {
+	f, err := a.fs.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive for verification: %v", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if identity != nil {
+		r, err = age.Decrypt(f, identity)
+		if err != nil {
+			return fmt.Errorf("failed to verify archive: %v", err)
+		}
+	}
+
+	found := make(map[string]bool, len(added))
+	err = readBundle(format, r, func(name string, r io.Reader) error {
+		expected, ok := added[name]
+		if !ok {
+			return nil
+		}
+		actual, err := checksumOf(r)
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return fmt.Errorf("entry %s does not match the original file", name)
+		}
+		found[name] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify archive: %v", err)
+	}
+
+	for name := range added {
+		if !found[name] {
+			return fmt.Errorf("failed to verify archive: entry %s is missing", name)
+		}
+	}
+	return nil
+}
+
+// readBundle calls fn for every entry of an archive. Zip archives can only be read from files.
+func readBundle(format string, in io.Reader, fn func(name string, r io.Reader) error) error {
+	if format == "zip" {
+		f, ok := in.(*os.File)
+		if !ok {
+			return fmt.Errorf("zip archives can only be read from files")
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return err
+		}
+		for _, entry := range zr.File {
+			r, err := entry.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(entry.Name, r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r := in
+	if format == "tar.gz" {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(header.Name, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// bundleWriter writes entries to an archive.
+type bundleWriter interface {
+	// copyExisting copies all entries of an existing archive and returns their names.
+	copyExisting(f *os.File) (map[string]bool, error)
+	// add writes a single file to the archive as entry name and records its original path.
+	add(info os.FileInfo, name, path string, r io.Reader) error
+	Close() error
+}
+
+// newBundleWriter returns the bundleWriter for an archive format.
+func newBundleWriter(format string, out io.Writer) bundleWriter {
+	switch format {
+	case "zip":
+		return &zipBundle{zip.NewWriter(out)}
+	case "tar.gz":
+		gz := gzip.NewWriter(out)
+		return &tarBundle{gz, tar.NewWriter(gz)}
+	}
+	return &tarBundle{nil, tar.NewWriter(out)}
+}
+
+// tarPathRecord is the PAX record the original path of a file is stored in.
+const tarPathRecord = "SCRUBBER.path"
+
+// tarBundle writes tar archives, optionally gzipped.
+type tarBundle struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+// copyExisting copies all entries of an existing tar archive.
+func (b *tarBundle) copyExisting(f *os.File) (map[string]bool, error) {
+	var r io.Reader = f
+	if b.gz != nil {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	names := make(map[string]bool)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = b.tw.WriteHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(b.tw, tr)
+		if err != nil {
+			return nil, err
+		}
+		names[header.Name] = true
+	}
+}
+
+// add writes a single file to the tar archive. The original path is stored in a PAX record.
+func (b *tarBundle) add(info os.FileInfo, name, path string, r io.Reader) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to create tar header: %v", err)
+	}
+	header.Name = name
+	header.PAXRecords = map[string]string{tarPathRecord: path}
+
+	err = b.tw.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(b.tw, r)
+	return err
+}
+
+// Close finishes the tar archive.
+func (b *tarBundle) Close() error {
+	if b.gz == nil {
+		return b.tw.Close()
+	}
+	return closeAll(b.tw, b.gz)
+}
+
+// zipBundle writes zip archives.
+type zipBundle struct {
+	zw *zip.Writer
+}
+
+// copyExisting copies all entries of an existing zip archive without recompressing them.
+func (b *zipBundle) copyExisting(f *os.File) (map[string]bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(zr.File))
+	for _, entry := range zr.File {
+		err = b.zw.Copy(entry)
+		if err != nil {
+			return nil, err
+		}
+		names[entry.Name] = true
+	}
+
+	return names, nil
+}
+
+// add writes a single file to the zip archive. The original path is stored as comment of the entry.
+func (b *zipBundle) add(info os.FileInfo, name, path string, r io.Reader) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to create zip header: %v", err)
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	header.Comment = path
+
+	w, err := b.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Close finishes the zip archive.
+func (b *zipBundle) Close() error {
+	return b.zw.Close()
+}
@@ -0,0 +1,238 @@
+package scrubber
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeTestFile creates a file with a certain content and modification time.
+func writeTestFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tarEntries returns the names and contents of all entries of a tar.gz archive.
+func tarEntries(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("expected %s to exist, got %v", path, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.ModTime.IsZero() || header.Mode != 0644 {
+			t.Errorf("expected %s to keep its metadata, got %s and %o", header.Name, header.ModTime, header.Mode)
+		}
+		entries[header.Name] = string(content)
+	}
+}
+
+// dirNames returns the sorted names of all files in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// TestBundle tests that files are collected in one tar.gz archive per month.
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	june := time.Date(2023, 6, 15, 12, 0, 0, 0, time.Local)
+	july := time.Date(2023, 7, 2, 12, 0, 0, 0, time.Local)
+
+	writeTestFile(t, dir+"/a.log", "aa", june)
+	writeTestFile(t, dir+"/b.log", "bb", june.AddDate(0, 0, 1))
+	writeTestFile(t, dir+"/c.log", "cc", july)
+
+	writeTestFile(t, dir+"/empty.log", "", june)
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeBundle, Archive: `archive-{{.Date "2006-01"}}.tar.gz`, Bucket: "month"}
+
+	runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, c)
+
+	june23 := tarEntries(t, dir+"/archive-2023-06.tar.gz")
+	if len(june23) != 2 || june23["a.log"] != "aa" || june23["b.log"] != "bb" {
+		t.Errorf("expected a.log and b.log in the june archive, got %v", june23)
+	}
+	july23 := tarEntries(t, dir+"/archive-2023-07.tar.gz")
+	if len(july23) != 1 || july23["c.log"] != "cc" {
+		t.Errorf("expected c.log in the july archive, got %v", july23)
+	}
+
+	names := dirNames(t, dir)
+	expected := []string{"archive-2023-06.tar.gz", "archive-2023-07.tar.gz", "empty.log"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v to remain, got %v", expected, names)
+	}
+
+	// A second run adds new files to the existing archive.
+	writeTestFile(t, dir+"/d.log", "dd", june.AddDate(0, 0, 2))
+	runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, c)
+
+	june23 = tarEntries(t, dir+"/archive-2023-06.tar.gz")
+	if len(june23) != 3 || june23["a.log"] != "aa" || june23["d.log"] != "dd" {
+		t.Errorf("expected a.log, b.log and d.log in the june archive, got %v", june23)
+	}
+
+	names = dirNames(t, dir)
+	expected = []string{"archive-2023-06.tar.gz", "archive-2023-07.tar.gz", "empty.log"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v to remain, got %v", expected, names)
+	}
+}
+
+// TestBundleZip tests that all files of a run are collected in one zip archive.
+func TestBundleZip(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir+"/a.log", "aa", time.Now())
+	writeTestFile(t, dir+"/b.log", "bb", time.Now())
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeBundle, Archive: `{{.DirName}}.zip`}
+
+	runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, c)
+
+	zr, err := zip.OpenReader(dir + "/" + path.Base(dir) + ".zip")
+	if err != nil {
+		t.Fatalf("expected the zip archive to exist, got %v", err)
+	}
+	defer zr.Close()
+
+	var entries []string
+	for _, f := range zr.File {
+		entries = append(entries, f.Name)
+	}
+	slices.Sort(entries)
+	if !slices.Equal(entries, []string{"a.log", "b.log"}) {
+		t.Errorf("expected a.log and b.log in the archive, got %v", entries)
+	}
+}
+
+// TestBundleSameName tests that files whose name is already taken by an entry
+// are added under a new name instead of replacing the entry.
+func TestBundleSameName(t *testing.T) {
+	dir := t.TempDir()
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeBundle, Archive: "archive.tar.gz"}
+	z := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeBundle, Archive: "archive.zip"}
+
+	for _, day := range []string{"day 1", "day 2", "day 3"} {
+		writeTestFile(t, dir+"/app.log", day, time.Now())
+		runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, c)
+		writeTestFile(t, dir+"/app.log", day, time.Now())
+		runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, z)
+	}
+
+	entries := tarEntries(t, dir+"/archive.tar.gz")
+	if len(entries) != 3 || entries["app.log"] != "day 1" || entries["app.1.log"] != "day 2" || entries["app.2.log"] != "day 3" {
+		t.Errorf("expected the files of all days in the archive, got %v", entries)
+	}
+
+	zr, err := zip.OpenReader(dir + "/archive.zip")
+	if err != nil {
+		t.Fatalf("expected the zip archive to exist, got %v", err)
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !slices.Equal(names, []string{"app.log", "app.1.log", "app.2.log"}) {
+		t.Errorf("expected the files of all days in the zip archive, got %v", names)
+	}
+}
+
+// TestBundleRace tests that an archive created while a bundle is written is never replaced.
+func TestBundleRace(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir+"/a.log", "aa", time.Now())
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeBundle, Archive: "archive.tar.gz"}
+	fs := &racingFs{path: dir + "/archive.tar.gz", content: "other"}
+	d := directory{Path: dir}
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a, err := newBundleAction(&c, &d, fs, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := fs.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = selectorStrategy{newSizeStrategy(&c, &d, a, logger), a}.process(files)
+	if err == nil {
+		t.Error("expected an error for an archive created in the meantime")
+	}
+
+	assertContent(t, dir+"/archive.tar.gz", "other")
+	assertContent(t, dir+"/a.log", "aa")
+	names := dirNames(t, dir)
+	if expected := []string{"a.log", "archive.tar.gz"}; !slices.Equal(names, expected) {
+		t.Errorf("expected %v to remain, got %v", expected, names)
+	}
+}
+
+// TestInvalidBundle tests that invalid bundle configurations are being rejected.
+func TestInvalidBundle(t *testing.T) {
+	configs := []StrategyConfig{
+		{},
+		{Archive: "archive.rar"},
+		{Archive: "archive-{{.Date}.zip"},
+		{Archive: "archive.zip", Bucket: "year"},
+	}
+
+	for _, c := range configs {
+		c := c
+		_, err := newBundleAction(&c, &directory{Path: testPath}, &mockedFs{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
@@ -4,25 +4,30 @@ import (
 	"flag"
 	"log"
 	"os"
+	"path/filepath"
 	"scrubber"
 
 	"github.com/BurntSushi/toml"
 )
 
 func main() {
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		restore(logger, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "decrypt" {
+		decrypt(logger, os.Args[2:])
+		return
+	}
+
 	cfgFile := flag.String("config", "scrubber.config.toml", "Path to the config file")
 	pretend := flag.Bool("pretend", false, "Print out actions that would be executed but do nothing")
 
 	flag.Parse()
 
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
-	logger.Printf("Loading configuration file %s", *cfgFile)
-
-	var conf scrubber.TomlConfig
-	if _, err := toml.DecodeFile(*cfgFile, &conf); err != nil {
-		logger.Fatalf("Could not decode config file: %s", err)
-		return
-	}
+	conf := loadConfig(logger, *cfgFile)
 
 	logger.Println("Beginning to scrub...")
 
@@ -34,3 +39,80 @@ func main() {
 		logger.Fatalf("error while scrubbing files: %s", err)
 	}
 }
+
+// restore puts files from a trash directory back to their original paths.
+func restore(logger *log.Logger, args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	cfgFile := flags.String("config", "scrubber.config.toml", "Path to the config file")
+	trash := flags.String("trash", "", "Path to the trash directory, defaults to all trash directories in the config file")
+	runID := flags.String("run", "", "Restore all files of a run")
+	pattern := flags.String("pattern", "", "Restore all files whose original path matches a pattern")
+
+	flags.Parse(args)
+
+	var paths []string
+	for _, arg := range flags.Args() {
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			logger.Fatalf("invalid path %s: %s", arg, err)
+		}
+		paths = append(paths, path)
+	}
+
+	filter := scrubber.RestoreFilter{RunID: *runID, Pattern: *pattern, Paths: paths}
+
+	var trashDirs []string
+	if *trash != "" {
+		trashDirs = []string{*trash}
+	} else {
+		conf := loadConfig(logger, *cfgFile)
+		trashDirs = conf.TrashDirs()
+		if len(trashDirs) == 0 {
+			logger.Fatalf("No trash directory found in %s", *cfgFile)
+		}
+	}
+
+	fs := scrubber.OSFilesystem{}
+	for _, dir := range trashDirs {
+		n, err := scrubber.Restore(fs, logger, dir, filter)
+		if err != nil {
+			logger.Fatalf("error while restoring files from %s: %s", dir, err)
+		}
+		logger.Printf("Restored %d files from %s", n, dir)
+	}
+}
+
+// decrypt decrypts encrypted archives with a private key that is supplied at runtime.
+func decrypt(logger *log.Logger, args []string) {
+	flags := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	identity := flags.String("identity", "", "Path to the file containing the private key")
+
+	flags.Parse(args)
+
+	if *identity == "" {
+		logger.Fatalf("decrypt requires an identity file")
+	}
+	if flags.NArg() == 0 {
+		logger.Fatalf("decrypt requires at least one archive")
+	}
+
+	fs := scrubber.OSFilesystem{}
+	for _, path := range flags.Args() {
+		target, err := scrubber.DecryptFile(fs, path, *identity)
+		if err != nil {
+			logger.Fatalf("error while decrypting files: %s", err)
+		}
+		logger.Printf("Decrypted %s to %s", path, target)
+	}
+}
+
+// loadConfig decodes the config file.
+func loadConfig(logger *log.Logger, cfgFile string) scrubber.TomlConfig {
+	logger.Printf("Loading configuration file %s", cfgFile)
+
+	var conf scrubber.TomlConfig
+	if _, err := toml.DecodeFile(cfgFile, &conf); err != nil {
+		logger.Fatalf("Could not decode config file: %s", err)
+	}
+	return conf
+}
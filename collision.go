@@ -0,0 +1,121 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CollisionPolicy defines what happens if a destination file already exists.
+type CollisionPolicy string
+
+const (
+	// CollisionSuffix appends a number to the name of the new file, like app.1.log.
+	CollisionSuffix CollisionPolicy = "suffix"
+	// CollisionSkip leaves the existing and the new file untouched.
+	CollisionSkip CollisionPolicy = "skip"
+	// CollisionOverwrite replaces the existing file.
+	CollisionOverwrite CollisionPolicy = "overwrite"
+	// CollisionFail aborts with an error.
+	CollisionFail CollisionPolicy = "fail"
+)
+
+// maxCollisionSuffix is the highest number that is tried as a suffix.
+const maxCollisionSuffix = 10000
+
+// validateCollision returns the policy to use, CollisionSuffix if none is set.
+func validateCollision(policy CollisionPolicy) (CollisionPolicy, error) {
+	switch policy {
+	case "":
+		return CollisionSuffix, nil
+	case CollisionSuffix, CollisionSkip, CollisionOverwrite, CollisionFail:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown collision policy %q, expected suffix, skip, overwrite or fail", policy)
+}
+
+// resolveCollision returns the path a file should be written to. If skip is true,
+// the file must not be written at all.
+func resolveCollision(fs Filesystem, path string, policy CollisionPolicy) (target string, skip bool, err error) {
+	_, err = fs.Stat(path)
+	if os.IsNotExist(err) {
+		return path, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	switch policy {
+	case CollisionSkip:
+		return "", true, nil
+	case CollisionOverwrite:
+		return path, false, nil
+	case CollisionFail:
+		return "", false, fmt.Errorf("destination %s already exists", path)
+	}
+
+	for i := 1; i <= maxCollisionSuffix; i++ {
+		candidate := collisionName(path, i)
+		_, err = fs.Stat(candidate)
+		if os.IsNotExist(err) {
+			return candidate, false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	return "", false, fmt.Errorf("no free name found for %s", path)
+}
+
+// collisionName returns path with the number i added in front of its extension,
+// like app.1.log. The number goes in front of the format of encrypted files, so
+// they keep it once decrypted.
+func collisionName(path string, i int) string {
+	ext := filepath.Ext(path)
+	if ext == encryptedExt {
+		ext = filepath.Ext(strings.TrimSuffix(path, ext)) + ext
+	}
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), i, ext)
+}
+
+// renameNoReplace renames src to the path resolveCollision returns for path,
+// without replacing a file that has been created since the name was resolved.
+// src is hard linked to the target, which atomically fails if the target exists,
+// and removed afterwards. If the name has been taken in the meantime, the
+// collision policy is applied again. Filesystems without hard links fall back to
+// a plain rename right after the name has been resolved. It returns the path src
+// has been renamed to.
+func renameNoReplace(fs Filesystem, src, path string, policy CollisionPolicy) (target string, skip bool, err error) {
+	for i := 0; i <= maxCollisionSuffix; i++ {
+		target, skip, err = resolveCollision(fs, path, policy)
+		if err != nil || skip {
+			return "", skip, err
+		}
+		if policy == CollisionOverwrite {
+			return target, false, fs.Rename(src, target)
+		}
+
+		err = fs.Link(src, target)
+		if os.IsExist(err) {
+			continue
+		}
+		if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, os.ErrPermission) {
+			return target, false, fs.Rename(src, target)
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		err = fs.Remove(src)
+		if err != nil {
+			fs.Remove(target)
+			return "", false, err
+		}
+		return target, false, nil
+	}
+
+	return "", false, fmt.Errorf("no free name found for %s", path)
+}
@@ -0,0 +1,84 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+)
+
+// compoundStrategy represents the action of cleaning up files that match a
+// combination of nested conditions.
+type compoundStrategy struct {
+	Strategy
+	fs         Filesystem
+	conditions []Selector
+}
+
+// newCompoundStrategy returns a new compoundStrategy. The nested conditions are
+// created right away, so invalid conditions are rejected before any file is touched.
+func newCompoundStrategy(c *StrategyConfig, dir *directory, fs Filesystem, action Action, log Logger) (*compoundStrategy, error) {
+	if len(c.Conditions) < 1 {
+		return nil, fmt.Errorf("compound strategy requires at least one condition")
+	}
+	if c.Match != MatchAll && c.Match != MatchAny && c.Match != "" {
+		return nil, fmt.Errorf("unknown match %q, expected %q or %q", c.Match, MatchAll, MatchAny)
+	}
+
+	conditions := make([]Selector, 0, len(c.Conditions))
+	for i := range c.Conditions {
+		condition := &c.Conditions[i]
+		nested, err := selectorFromConfig(condition, dir, fs, nil, log)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s condition: %s", condition.Type, err)
+		}
+		conditions = append(conditions, nested)
+	}
+
+	return &compoundStrategy{Strategy{c, dir, action, log}, fs, conditions}, nil
+}
+
+// Select selects files that match all or any of the nested conditions. Each
+// condition can be negated using not.
+func (s compoundStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	checks := make([]checkFn, 0, len(s.conditions))
+	for i, nested := range s.conditions {
+		condition := &s.c.Conditions[i]
+
+		check, err := nested.Select(files)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s condition: %s", condition.Type, err)
+		}
+
+		if condition.Not {
+			check = negate(check)
+		}
+
+		checks = append(checks, check)
+	}
+
+	if s.c.Match == MatchAny {
+		return func(file os.FileInfo) bool {
+			for _, check := range checks {
+				if check(file) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	return func(file os.FileInfo) bool {
+		for _, check := range checks {
+			if !check(file) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// negate returns a checkFn that selects all files check does not select.
+func negate(check checkFn) checkFn {
+	return func(file os.FileInfo) bool {
+		return !check(file)
+	}
+}
@@ -0,0 +1,151 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// compoundTestFiles are the files used by all compound strategy tests.
+var compoundTestFiles = []os.FileInfo{
+	mockedFileInfo{name: "old-big", size: 200, modTime: time.Now().AddDate(0, 0, -60)},
+	mockedFileInfo{name: "old-small", size: 10, modTime: time.Now().AddDate(0, 0, -60)},
+	mockedFileInfo{name: "new-big", size: 200, modTime: time.Now()},
+	mockedFileInfo{name: "new-small", size: 10, modTime: time.Now()},
+}
+
+// TestCompound tests that nested conditions are combined correctly.
+func TestCompound(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   StrategyConfig
+		expected []string
+	}{
+		{
+			name: "all",
+			config: StrategyConfig{Match: MatchAll, Conditions: []StrategyConfig{
+				{Type: StrategyTypeAge, Limit: "30d"},
+				{Type: StrategyTypeSize, Limit: "100b"},
+			}},
+			expected: []string{"old-big"},
+		},
+		{
+			name: "any",
+			config: StrategyConfig{Match: MatchAny, Conditions: []StrategyConfig{
+				{Type: StrategyTypeAge, Limit: "30d"},
+				{Type: StrategyTypeSize, Limit: "100b"},
+			}},
+			expected: []string{"new-big", "old-big", "old-small"},
+		},
+		{
+			name: "not",
+			config: StrategyConfig{Conditions: []StrategyConfig{
+				{Type: StrategyTypeAge, Limit: "30d"},
+				{Type: StrategyTypeSize, Limit: "100b", Not: true},
+			}},
+			expected: []string{"old-small"},
+		},
+		{
+			name: "nested",
+			config: StrategyConfig{Match: MatchAny, Conditions: []StrategyConfig{
+				{Type: StrategyTypeCompound, Conditions: []StrategyConfig{
+					{Type: StrategyTypeAge, Limit: "30d"},
+					{Type: StrategyTypeSize, Limit: "100b"},
+				}},
+				{Type: StrategyTypeSize, Limit: "100b", Not: true},
+			}},
+			expected: []string{"new-small", "old-big", "old-small"},
+		},
+	}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	for _, test := range tests {
+		fs := &mockedFs{}
+		c := test.config
+		c.Type = StrategyTypeCompound
+		c.Action = ActionTypeDelete
+		d := directory{Path: testPath}
+
+		a := newDeleteAction(&d, fs, logger, false)
+		s, err := newCompoundStrategy(&c, &d, fs, a, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = selectorStrategy{s, a}.process(compoundTestFiles)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v\n", test.name, err)
+		}
+
+		var expected []string
+		for _, name := range test.expected {
+			expected = append(expected, testPath+"/"+name)
+		}
+
+		slices.Sort(fs.deleted)
+		if !slices.Equal(fs.deleted, expected) {
+			t.Errorf("%s: expected %v to be removed got %v.\n", test.name, expected, fs.deleted)
+		}
+	}
+}
+
+// TestInvalidCompound tests that invalid compound strategies are being rejected.
+func TestInvalidCompound(t *testing.T) {
+	configs := []StrategyConfig{
+		{},
+		{Match: "some", Conditions: []StrategyConfig{{Type: StrategyTypeAge, Limit: "1d"}}},
+		{Conditions: []StrategyConfig{{Type: "unknown", Limit: "1d"}}},
+		{Conditions: []StrategyConfig{{Type: StrategyTypeCompound, Conditions: []StrategyConfig{{Type: "unknown"}}}}},
+		{Conditions: []StrategyConfig{{Type: StrategyTypeExpr, Limit: "size >"}}},
+	}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	for _, c := range configs {
+		c := c
+		d := directory{Path: testPath}
+		_, err := newCompoundStrategy(&c, &d, &mockedFs{}, nil, logger)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+
+	// Limits are parsed once the files are selected.
+	c := StrategyConfig{Conditions: []StrategyConfig{{Type: StrategyTypeAge, Limit: "1x"}}}
+	s, err := newCompoundStrategy(&c, &directory{Path: testPath}, &mockedFs{}, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Select(compoundTestFiles)
+	if err == nil {
+		t.Errorf("expected an error for %+v", c)
+	}
+}
+
+// TestInvalidCompoundConfig tests that invalid conditions and not on top-level strategies
+// are rejected before any directory is scrubbed.
+func TestInvalidCompoundConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir+"/app.log", "app", time.Now().AddDate(0, 0, -60))
+
+	strategies := []StrategyConfig{
+		{Type: StrategyTypeCompound, Action: ActionTypeDelete, Conditions: []StrategyConfig{{Type: "unknown"}}},
+		{Type: StrategyTypeAge, Action: ActionTypeDelete, Limit: "30d", Not: true},
+	}
+	for _, strategy := range strategies {
+		// The first directory is valid, so it would be scrubbed by a run that fails late.
+		c := &TomlConfig{Directories: []directory{
+			{Path: dir, Strategies: []StrategyConfig{{Type: StrategyTypeAge, Action: ActionTypeDelete, Limit: "30d"}}},
+			{Path: dir, Strategies: []StrategyConfig{strategy}},
+		}}
+		err := New(c, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+		if err == nil {
+			t.Errorf("expected an error for %+v", strategy)
+		}
+		assertContent(t, dir+"/app.log", "app")
+	}
+}
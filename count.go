@@ -0,0 +1,57 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// countStrategy represents the action of cleaning up all files but the newest n.
+type countStrategy struct {
+	Strategy
+	limit int
+}
+
+// newCountStrategy returns a new countStrategy.
+func newCountStrategy(c *StrategyConfig, dir *directory, action Action, log Logger) *countStrategy {
+	return &countStrategy{Strategy{c, dir, action, log}, 0}
+}
+
+// Select selects files that are not among the newest n files.
+func (s countStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	err := s.unmarshalText([]byte(s.c.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := slices.Clone(files)
+	sortNewestFirst(sorted)
+
+	keep := make(map[string]bool, s.limit)
+	for i := 0; i < len(sorted) && i < s.limit; i++ {
+		keep[sorted[i].Name()] = true
+	}
+
+	return func(file os.FileInfo) bool {
+		return !keep[file.Name()]
+	}, nil
+}
+
+// unmarshalText turns a string representation of a file count into an int.
+func (s *countStrategy) unmarshalText(text []byte) error {
+	limit := strings.TrimSpace(string(text))
+	if limit == "" {
+		return fmt.Errorf("limit cannot be an empty string")
+	}
+
+	count, err := strconv.Atoi(limit)
+	if err != nil || count < 0 {
+		return fmt.Errorf("invalid file count %q passed to CountStrategy", limit)
+	}
+
+	s.limit = count
+
+	return nil
+}
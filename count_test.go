@@ -0,0 +1,90 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestCount tests that all files but the newest n are being deleted.
+func TestCount(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "weekold", modTime: time.Now().AddDate(0, 0, -7)},
+		mockedFileInfo{name: "dayold", modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "yearold", modTime: time.Now().AddDate(-1, 0, 0)},
+		mockedFileInfo{name: "hourold", modTime: time.Now().Add(-1 * time.Hour)},
+	}
+
+	fs := &mockedFs{}
+
+	c := StrategyConfig{Type: StrategyTypeCount, Limit: "2", Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newCountStrategy(&c, &d, a, logger)
+
+	remaining, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 2 || fs.deleted[0] != testPath+"/weekold" || fs.deleted[1] != testPath+"/yearold" {
+		t.Errorf("expected \"weekold\" and \"yearold\" to be removed got %v.\n", fs.deleted)
+	}
+
+	if len(remaining) != 2 {
+		t.Errorf("expected 2 files to remain, got %d.\n", len(remaining))
+	}
+}
+
+// TestCountBelowLimit tests that no files are touched if there are fewer files than the limit.
+func TestCountBelowLimit(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "dayold", modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "weekold", modTime: time.Now().AddDate(0, 0, -7)},
+	}
+
+	fs := &mockedFs{}
+
+	c := StrategyConfig{Type: StrategyTypeCount, Limit: "50", Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newCountStrategy(&c, &d, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestInvalidCountLimitParser tests that invalid limit strings are being rejected.
+func TestInvalidCountLimitParser(t *testing.T) {
+	testCounts := []struct {
+		test string
+	}{
+		{"-1"},
+		{"10d"},
+		{""},
+		{"/"},
+	}
+
+	s := newCountStrategy(nil, &directory{Path: "test"}, nil, nil)
+
+	for _, table := range testCounts {
+		err := s.unmarshalText([]byte(table.test))
+		if err == nil {
+			t.Errorf("UnmarshalText(%q) should return an error", table.test)
+		}
+	}
+}
@@ -10,12 +10,12 @@ type deleteAction struct {
 }
 
 // newDeleteAction returns a pointer to a deleteAction.
-func newDeleteAction(dir *directory, fs Filesystem, log logger, pretend bool) *deleteAction {
+func newDeleteAction(dir *directory, fs Filesystem, log Logger, pretend bool) *deleteAction {
 	return &deleteAction{action{dir, fs, log, pretend}}
 }
 
-// perform deletes files that are past a certain age or certain size.
-func (a deleteAction) perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+// Perform deletes files that are past a certain age or certain size.
+func (a deleteAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
 	var newFiles []os.FileInfo
 	for _, file := range files {
 		file := file
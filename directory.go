@@ -2,6 +2,7 @@ package scrubber
 
 import (
 	"os"
+	"slices"
 )
 
 // directory holds the cleanup information for a single path in the filesystem.
@@ -11,7 +12,14 @@ type directory struct {
 	Include    []string
 	Exclude    []string
 	Strategies []StrategyConfig `toml:"strategy"`
-	KeepLatest int
+	KeepLatest int              `toml:"keep_latest"`
+	Hooks      []HookConfig     `toml:"hook"`
+
+	NameTimePattern string `toml:"name_time_pattern"`
+	NameTimeLayout  string `toml:"name_time_layout"`
+
+	// runID identifies the run the directory is scrubbed in.
+	runID string
 }
 
 // WithPath returns a copy of the struct with the Path field set to dir.
@@ -22,6 +30,11 @@ func (d directory) WithPath(dir string) directory {
 		Include:    d.Include,
 		Exclude:    d.Exclude,
 		Strategies: d.Strategies,
+		KeepLatest: d.KeepLatest,
+		Hooks:      d.Hooks,
+
+		NameTimePattern: d.NameTimePattern,
+		NameTimeLayout:  d.NameTimeLayout,
 	}
 }
 
@@ -48,7 +61,7 @@ func (s directoryScanner) getFiles() ([]os.FileInfo, error) {
 func (s directoryScanner) filterFiles(files []os.FileInfo) []os.FileInfo {
 	var filtered []os.FileInfo
 	for _, file := range files {
-		if !file.Mode().IsRegular() {
+		if !file.Mode().IsRegular() || isTempFile(file.Name()) {
 			continue
 		}
 
@@ -91,3 +104,10 @@ func ApplyKeepLatest(files []os.FileInfo, latest int) []os.FileInfo {
 
 	return files
 }
+
+// sortNewestFirst sorts a slice of files by their modification time, newest first.
+func sortNewestFirst(files []os.FileInfo) {
+	slices.SortStableFunc(files, func(i, j os.FileInfo) int {
+		return j.ModTime().Compare(i.ModTime())
+	})
+}
@@ -0,0 +1,122 @@
+package scrubber
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// encryptedExt is appended to the names of encrypted archives.
+const encryptedExt = ".age"
+
+// newRecipients returns the age recipients archives are encrypted for, or nil if encryption is disabled.
+func newRecipients(c *StrategyConfig) ([]age.Recipient, error) {
+	if !c.Encrypt {
+		if len(c.Recipients) > 0 {
+			return nil, fmt.Errorf("recipients are only used together with encrypt")
+		}
+		return nil, nil
+	}
+	if len(c.Recipients) == 0 {
+		return nil, fmt.Errorf("encrypt requires at least one recipient")
+	}
+
+	recipients := make([]age.Recipient, 0, len(c.Recipients))
+	for _, key := range c.Recipients {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %v", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// encryptTo returns a writer that encrypts everything written to dst for all recipients.
+// The file is additionally encrypted for a new identity that is only kept in memory,
+// so the archive can be verified without access to the private keys of the recipients.
+func encryptTo(dst io.Writer, recipients []age.Recipient) (io.WriteCloser, age.Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := age.Encrypt(dst, append(recipients, identity.Recipient())...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encrypt: %v", err)
+	}
+	return w, identity, nil
+}
+
+// DecryptFile decrypts an encrypted archive using the private keys in identityFile and
+// writes the result next to it, without the .age extension. The result gets the mode
+// and modification time of the encrypted archive. Existing files are never replaced,
+// not even if they are created while the archive is decrypted. It returns the path
+// of the decrypted file.
+func DecryptFile(fs Filesystem, path, identityFile string) (string, error) {
+	if !strings.HasSuffix(path, encryptedExt) {
+		return "", fmt.Errorf("%s is not an encrypted archive", path)
+	}
+	target := strings.TrimSuffix(path, encryptedExt)
+	if _, err := fs.Stat(target); err == nil {
+		return "", fmt.Errorf("%s already exists", target)
+	}
+
+	keys, err := fs.Open(identityFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to open identity file: %v", err)
+	}
+	identities, err := age.ParseIdentities(keys)
+	keys.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read identity file: %v", err)
+	}
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	in, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %v", path, err)
+	}
+
+	// Only the owner can read the decrypted content until it is complete.
+	tmpPath := tempPath(target)
+	out, err := fs.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(out, r)
+	if err == nil {
+		err = syncClose(out)
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = fs.Chmod(tmpPath, info.Mode().Perm())
+	}
+	if err == nil {
+		err = fs.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		_, _, err = renameNoReplace(fs, tmpPath, target, CollisionFail)
+	}
+	if err != nil {
+		fs.Remove(tmpPath)
+		return "", fmt.Errorf("failed to decrypt %s: %v", path, err)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,227 @@
+package scrubber
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+// writeIdentity generates a new identity, stores it in an identity file and returns its path and public key.
+func writeIdentity(t *testing.T) (string, string) {
+	t.Helper()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.txt")
+	err = os.WriteFile(path, []byte("# created for a test\n"+identity.String()+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, identity.Recipient().String()
+}
+
+// TestEncryptGzip tests that an encrypted gzip archive can be decrypted with the private key.
+func TestEncryptGzip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	modTime := time.Now().AddDate(0, 0, -3).Truncate(time.Second)
+	writeTestFile(t, path, "secret content", modTime)
+
+	identityFile, recipient := writeIdentity(t)
+	_, other := writeIdentity(t)
+
+	c := StrategyConfig{Encrypt: true, Recipients: []string{recipient, other}}
+	a, err := newGzipAction(&c, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.gzip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path + ".gz.age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "age-encryption.org/v1") {
+		t.Errorf("expected an age encrypted archive")
+	}
+	assertMissing(t, path+".gz")
+
+	_, err = DecryptFile(OSFilesystem{}, path+".gz.age", path)
+	if err == nil {
+		t.Errorf("expected an error for an invalid identity file")
+	}
+
+	target, err := DecryptFile(OSFilesystem{}, path+".gz.age", identityFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != path+".gz" {
+		t.Errorf("expected the archive to be decrypted to %s, got %s", path+".gz", target)
+	}
+	assertMetadata(t, target, modTime, 0644)
+
+	f, err := os.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "secret content" {
+		t.Errorf("expected the original content, got %q", plain)
+	}
+
+	_, err = DecryptFile(OSFilesystem{}, path+".gz.age", identityFile)
+	if err == nil {
+		t.Errorf("expected an error because the decrypted file already exists")
+	}
+}
+
+// TestDecryptRace tests that a file created while an archive is decrypted is never replaced.
+func TestDecryptRace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "secret content", time.Now())
+
+	identityFile, recipient := writeIdentity(t)
+
+	c := StrategyConfig{Encrypt: true, Recipients: []string{recipient}}
+	a, err := newGzipAction(&c, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.gzip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := &racingFs{path: path + ".gz", content: "other"}
+	_, err = DecryptFile(fs, path+".gz.age", identityFile)
+	if err == nil {
+		t.Errorf("expected an error because the decrypted file has been created in the meantime")
+	}
+
+	assertContent(t, path+".gz", "other")
+	names := dirNames(t, dir)
+	if expected := []string{"app.log", "app.log.gz", "app.log.gz.age"}; !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+// TestEncryptXzPathFile tests that the path file of an encrypted xz archive is encrypted as well.
+func TestEncryptXzPathFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "secret content", time.Now())
+
+	identityFile, recipient := writeIdentity(t)
+
+	c := StrategyConfig{Encrypt: true, Recipients: []string{recipient}}
+	a, err := newXzAction(&c, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.xz(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := dirNames(t, dir)
+	if expected := []string{"app.log", "app.log.xz.age", "app.log.xz.path.age"}; !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	target, err := DecryptFile(OSFilesystem{}, path+".xz.path.age", identityFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, target, path+"\n")
+}
+
+// TestEncryptBundle tests that encrypted bundles are never extended and can be decrypted.
+func TestEncryptBundle(t *testing.T) {
+	dir := t.TempDir()
+	identityFile, recipient := writeIdentity(t)
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeBundle, Archive: "archive.tar.gz", Encrypt: true, Recipients: []string{recipient}}
+
+	writeTestFile(t, dir+"/a.log", "aa", time.Now())
+	runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, c)
+	writeTestFile(t, dir+"/b.log", "bb", time.Now())
+	runAction(t, OSFilesystem{}, directory{Path: dir, Include: []string{"log"}}, c)
+
+	names := dirNames(t, dir)
+	expected := []string{"archive.tar.1.gz.age", "archive.tar.gz.age"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	for archive, entry := range map[string]string{"archive.tar.gz.age": "a.log", "archive.tar.1.gz.age": "b.log"} {
+		target, err := DecryptFile(OSFilesystem{}, filepath.Join(dir, archive), identityFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := tarEntries(t, target)
+		if len(entries) != 1 || entries[entry] == "" {
+			t.Errorf("expected only %s in %s, got %v", entry, archive, entries)
+		}
+	}
+}
+
+// TestEncryptConfig tests that invalid encryption settings are rejected.
+func TestEncryptConfig(t *testing.T) {
+	_, recipient := writeIdentity(t)
+	dir := &directory{Path: t.TempDir()}
+	logger := log.New(ioutil.Discard, "", 0)
+
+	invalid := []StrategyConfig{
+		{Encrypt: true},
+		{Recipients: []string{recipient}},
+		{Encrypt: true, Recipients: []string{"not a key"}},
+	}
+	for _, c := range invalid {
+		_, err := newZstdAction(&c, dir, OSFilesystem{}, logger, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+
+	c := StrategyConfig{Encrypt: true, Recipients: []string{recipient}}
+	_, err := newZipAction(&c, dir, OSFilesystem{}, logger, false)
+	if err == nil {
+		t.Errorf("expected an error for an encrypted zip archive")
+	}
+
+	c.Archive = "archive.zip"
+	_, err = newBundleAction(&c, dir, OSFilesystem{}, logger, false)
+	if err == nil {
+		t.Errorf("expected an error for an encrypted zip bundle")
+	}
+
+	c.Archive = "archive.tar"
+	_, err = newBundleAction(&c, dir, OSFilesystem{}, logger, false)
+	if err != nil {
+		t.Errorf("expected no error for an encrypted tar bundle, got %v", err)
+	}
+}
@@ -0,0 +1,548 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// exprType is the static type of an expression node.
+type exprType int
+
+const (
+	exprBool exprType = iota
+	exprNumber
+	exprDuration
+	exprString
+)
+
+// String returns the name of an expression type as it is used in error messages.
+func (t exprType) String() string {
+	switch t {
+	case exprBool:
+		return "bool"
+	case exprNumber:
+		return "number"
+	case exprDuration:
+		return "duration"
+	}
+	return "string"
+}
+
+// exprEnv holds the attributes of the file an expression is evaluated against.
+type exprEnv struct {
+	file os.FileInfo
+	ext  string
+	path string
+	dir  string
+	age  time.Duration
+}
+
+// exprNode is a compiled part of an expression.
+type exprNode struct {
+	typ  exprType
+	pos  int
+	eval func(env *exprEnv) interface{}
+}
+
+// exprProgram is a compiled expression.
+type exprProgram struct {
+	root    exprNode
+	usesAge bool
+}
+
+// exprError is returned for expressions that cannot be compiled.
+type exprError struct {
+	pos int
+	msg string
+}
+
+// Error returns the error message including the position the error occurred at.
+func (e exprError) Error() string {
+	return fmt.Sprintf("invalid expression at position %d: %s", e.pos, e.msg)
+}
+
+// exprAttributes are all file attributes an expression can refer to.
+var exprAttributes = map[string]exprNode{
+	"name": {typ: exprString, eval: func(env *exprEnv) interface{} { return env.file.Name() }},
+	"ext":  {typ: exprString, eval: func(env *exprEnv) interface{} { return env.ext }},
+	"path": {typ: exprString, eval: func(env *exprEnv) interface{} { return env.path }},
+	"dir":  {typ: exprString, eval: func(env *exprEnv) interface{} { return env.dir }},
+	"size": {typ: exprNumber, eval: func(env *exprEnv) interface{} { return float64(env.file.Size()) }},
+	"age":  {typ: exprDuration, eval: func(env *exprEnv) interface{} { return env.age }},
+}
+
+// exprMethods are all methods that can be called on a string.
+var exprMethods = map[string]func(a, b string) bool{
+	"contains":   strings.Contains,
+	"startsWith": strings.HasPrefix,
+	"endsWith":   strings.HasSuffix,
+}
+
+// compileExpr compiles an expression.
+func compileExpr(source string) (*exprProgram, error) {
+	tokens, err := lexExpr(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, exprError{p.peek().pos, fmt.Sprintf("unexpected %q", p.peek().text)}
+	}
+	if root.typ != exprBool {
+		return nil, exprError{root.pos, fmt.Sprintf("expression has to be a bool, got %s", root.typ)}
+	}
+
+	return &exprProgram{root: root, usesAge: p.usesAge}, nil
+}
+
+// match evaluates the program against env.
+func (p exprProgram) match(env *exprEnv) bool {
+	return p.root.eval(env).(bool)
+}
+
+// tokenKind is the kind of a lexical token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+// token is a single lexical token of an expression.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprOperators are all operators, longest first.
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ".", ","}
+
+// lexExpr splits an expression into tokens. Positions are 1-based.
+func lexExpr(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start + 1})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), start + 1})
+		case r == '"' || r == '\'':
+			start := i
+			var value strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				// Only quotes and backslashes are unescaped, so regular expressions like \d keep their backslash.
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(`"'\`, runes[i+1]) {
+					i++
+				}
+				value.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, exprError{start + 1, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, token{tokenString, value.String(), start + 1})
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{tokenOperator, op, i + 1})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, exprError{i + 1, fmt.Sprintf("unexpected character %q", r)}
+			}
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "end of expression", len(runes) + 1}), nil
+}
+
+// exprParser turns tokens into a tree of exprNodes.
+type exprParser struct {
+	tokens  []token
+	i       int
+	usesAge bool
+}
+
+// peek returns the current token.
+func (p *exprParser) peek() token {
+	return p.tokens[p.i]
+}
+
+// next returns the current token and advances to the next one.
+func (p *exprParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept advances to the next token if the current one is the operator op.
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// expect advances to the next token or returns an error if the current one is not the operator op.
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		return exprError{p.peek().pos, fmt.Sprintf("expected %q, got %q", op, p.peek().text)}
+	}
+	return nil
+}
+
+// parseOr parses a || b.
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return left, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.accept("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return right, err
+		}
+		if left.typ != exprBool || right.typ != exprBool {
+			return left, exprError{pos, fmt.Sprintf("|| requires bool operands, got %s and %s", left.typ, right.typ)}
+		}
+		l, r := left.eval, right.eval
+		left = exprNode{exprBool, left.pos, func(env *exprEnv) interface{} {
+			return l(env).(bool) || r(env).(bool)
+		}}
+	}
+}
+
+// parseAnd parses a && b.
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return left, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.accept("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return right, err
+		}
+		if left.typ != exprBool || right.typ != exprBool {
+			return left, exprError{pos, fmt.Sprintf("&& requires bool operands, got %s and %s", left.typ, right.typ)}
+		}
+		l, r := left.eval, right.eval
+		left = exprNode{exprBool, left.pos, func(env *exprEnv) interface{} {
+			return l(env).(bool) && r(env).(bool)
+		}}
+	}
+}
+
+// parseUnary parses !a.
+func (p *exprParser) parseUnary() (exprNode, error) {
+	pos := p.peek().pos
+	if !p.accept("!") {
+		return p.parseComparison()
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return operand, err
+	}
+	if operand.typ != exprBool {
+		return operand, exprError{pos, fmt.Sprintf("! requires a bool operand, got %s", operand.typ)}
+	}
+	eval := operand.eval
+	return exprNode{exprBool, pos, func(env *exprEnv) interface{} {
+		return !eval(env).(bool)
+	}}, nil
+}
+
+// parseComparison parses a < b, a == b and all other comparisons.
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return left, err
+	}
+
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return right, err
+	}
+	if left.typ != right.typ {
+		return left, exprError{t.pos, fmt.Sprintf("cannot compare %s with %s", left.typ, right.typ)}
+	}
+	if left.typ == exprBool && t.text != "==" && t.text != "!=" {
+		return left, exprError{t.pos, fmt.Sprintf("operator %s is not defined for bool", t.text)}
+	}
+
+	l, r, op := left.eval, right.eval, t.text
+	return exprNode{exprBool, left.pos, func(env *exprEnv) interface{} {
+		return compareExpr(l(env), r(env), op)
+	}}, nil
+}
+
+// compareExpr compares two values of the same type using op.
+func compareExpr(a, b interface{}, op string) bool {
+	var cmp int
+	switch a := a.(type) {
+	case bool:
+		if op == "==" {
+			return a == b.(bool)
+		}
+		return a != b.(bool)
+	case float64:
+		cmp = compareOrdered(a, b.(float64))
+	case time.Duration:
+		cmp = compareOrdered(a, b.(time.Duration))
+	case string:
+		cmp = strings.Compare(a, b.(string))
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// compareOrdered returns -1, 0 or 1 depending on whether a is less, equal or greater than b.
+func compareOrdered[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parsePrimary parses literals, attributes, method calls and parenthesized expressions.
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return parseExprNumber(t)
+	case tokenString:
+		value := t.text
+		return exprNode{exprString, t.pos, func(*exprEnv) interface{} { return value }}, nil
+	case tokenIdent:
+		var node exprNode
+		switch t.text {
+		case "true", "false":
+			value := t.text == "true"
+			node = exprNode{exprBool, t.pos, func(*exprEnv) interface{} { return value }}
+		default:
+			attribute, ok := exprAttributes[t.text]
+			if !ok {
+				return node, exprError{t.pos, fmt.Sprintf("unknown attribute %q", t.text)}
+			}
+			if t.text == "age" {
+				p.usesAge = true
+			}
+			node = exprNode{attribute.typ, t.pos, attribute.eval}
+		}
+		for p.accept(".") {
+			var err error
+			node, err = p.parseMethod(node)
+			if err != nil {
+				return node, err
+			}
+		}
+		return node, nil
+	case tokenOperator:
+		if t.text == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return node, err
+			}
+			return node, p.expect(")")
+		}
+	}
+
+	return exprNode{}, exprError{t.pos, fmt.Sprintf("unexpected %q", t.text)}
+}
+
+// parseMethod parses a method call on a string like name.matches("^keep-").
+func (p *exprParser) parseMethod(receiver exprNode) (exprNode, error) {
+	method := p.next()
+	if method.kind != tokenIdent {
+		return receiver, exprError{method.pos, fmt.Sprintf("expected a method name, got %q", method.text)}
+	}
+	if receiver.typ != exprString {
+		return receiver, exprError{method.pos, fmt.Sprintf("method %s is not defined for %s", method.text, receiver.typ)}
+	}
+	if err := p.expect("("); err != nil {
+		return receiver, err
+	}
+
+	arg := p.peek()
+	next := p.tokens[min(p.i+1, len(p.tokens)-1)]
+	literal := arg.kind == tokenString && next.kind == tokenOperator && next.text == ")"
+
+	argument, err := p.parseOr()
+	if err != nil {
+		return argument, err
+	}
+	if argument.typ != exprString {
+		return argument, exprError{arg.pos, fmt.Sprintf("method %s requires a string argument, got %s", method.text, argument.typ)}
+	}
+	if err := p.expect(")"); err != nil {
+		return receiver, err
+	}
+
+	self := receiver.eval
+	if method.text == "matches" {
+		if !literal {
+			return receiver, exprError{arg.pos, "method matches requires a string literal"}
+		}
+		re, err := regexp.Compile(arg.text)
+		if err != nil {
+			return receiver, exprError{arg.pos, fmt.Sprintf("invalid regular expression: %s", err)}
+		}
+		return exprNode{exprBool, receiver.pos, func(env *exprEnv) interface{} {
+			return re.MatchString(self(env).(string))
+		}}, nil
+	}
+
+	fn, ok := exprMethods[method.text]
+	if !ok {
+		return receiver, exprError{method.pos, fmt.Sprintf("unknown method %q", method.text)}
+	}
+	other := argument.eval
+	return exprNode{exprBool, receiver.pos, func(env *exprEnv) interface{} {
+		return fn(self(env).(string), other(env).(string))
+	}}, nil
+}
+
+// parseExprNumber parses a plain number, a size like 10MB or a duration like 7d.
+func parseExprNumber(t token) (exprNode, error) {
+	digits := strings.TrimRightFunc(t.text, unicode.IsLetter)
+	unit := t.text[len(digits):]
+
+	if unit == "" {
+		value, err := strconv.ParseFloat(digits, 64)
+		if err != nil {
+			return exprNode{}, exprError{t.pos, fmt.Sprintf("invalid number %q", t.text)}
+		}
+		return exprNode{exprNumber, t.pos, func(*exprEnv) interface{} { return value }}, nil
+	}
+
+	if _, ok := durationUnits[unit]; ok {
+		value, err := parseAge(t.text)
+		if err != nil {
+			return exprNode{}, exprError{t.pos, fmt.Sprintf("invalid duration %q", t.text)}
+		}
+		return exprNode{exprDuration, t.pos, func(*exprEnv) interface{} { return value }}, nil
+	}
+
+	size, err := parseSize([]byte(t.text))
+	if err != nil {
+		return exprNode{}, exprError{t.pos, fmt.Sprintf("invalid size %q", t.text)}
+	}
+	value := float64(size)
+	return exprNode{exprNumber, t.pos, func(*exprEnv) interface{} { return value }}, nil
+}
+
+// exprStrategy represents the action of cleaning up files that match an expression.
+type exprStrategy struct {
+	Strategy
+	fs      Filesystem
+	program *exprProgram
+}
+
+// newExprStrategy returns a new exprStrategy. The expression in c.Limit is compiled right away.
+func newExprStrategy(c *StrategyConfig, dir *directory, fs Filesystem, action Action, log Logger) (*exprStrategy, error) {
+	program, err := compileExpr(c.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &exprStrategy{Strategy{c, dir, action, log}, fs, program}, nil
+}
+
+// Select selects files that match the expression.
+func (s exprStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	fileTime, err := s.fileTime()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return func(file os.FileInfo) bool {
+		env := &exprEnv{
+			file: file,
+			ext:  strings.TrimPrefix(s.fs.Ext(file), "."),
+			path: s.fs.FullPath(file, s.dir.Path),
+			dir:  s.dir.Path,
+		}
+		if s.program.usesAge {
+			t, err := fileTime(file)
+			if err != nil {
+				s.log.Printf("[Expr] Skipping file %s: %s", file.Name(), err)
+				return false
+			}
+			env.age = now.Sub(t)
+		}
+		return s.program.match(env)
+	}, nil
+}
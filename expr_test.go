@@ -0,0 +1,117 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExpr tests that files matching an expression are being deleted.
+func TestExpr(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "old-big.log", size: 20 * 1024 * 1024, modTime: time.Now().AddDate(0, 0, -10)},
+		mockedFileInfo{name: "keep-big.log", size: 20 * 1024 * 1024, modTime: time.Now().AddDate(0, 0, -10)},
+		mockedFileInfo{name: "new-big.log", size: 20 * 1024 * 1024, modTime: time.Now()},
+		mockedFileInfo{name: "old-small.log", size: 10, modTime: time.Now().AddDate(0, 0, -10)},
+		mockedFileInfo{name: "old-small.tmp", size: 10, modTime: time.Now().AddDate(0, 0, -10)},
+		mockedFileInfo{name: "app-12.log", size: 10, modTime: time.Now()},
+	}
+
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{`size > 10MB && age > 7d && !name.matches("^keep-")`, []string{"old-big.log"}},
+		{`ext == "tmp" || (size >= 1KB && name.startsWith("new"))`, []string{"new-big.log", "old-small.tmp"}},
+		{`(age < 1d) == false && name.endsWith('.log') && !name.contains("big")`, []string{"old-small.log"}},
+		{`path == "/logs/keep-big.log"`, []string{"keep-big.log"}},
+		{`name.matches("^old-\w+\.log$")`, []string{"old-big.log", "old-small.log"}},
+		{`name.matches('^\w+-\d+\.log$') && "\"\\" == '"\\'`, []string{"app-12.log"}},
+	}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	for _, test := range tests {
+		fs := &mockedFs{}
+		c := StrategyConfig{Type: StrategyTypeExpr, Limit: test.expr, Action: ActionTypeDelete}
+		d := directory{Path: testPath}
+
+		a := newDeleteAction(&d, fs, logger, false)
+		s, err := newExprStrategy(&c, &d, fs, a, logger)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v\n", test.expr, err)
+		}
+
+		_, err = selectorStrategy{s, a}.process(files)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v\n", test.expr, err)
+		}
+
+		var expected []string
+		for _, name := range test.expected {
+			expected = append(expected, testPath+"/"+name)
+		}
+
+		slices.Sort(fs.deleted)
+		if !slices.Equal(fs.deleted, expected) {
+			t.Errorf("%s: expected %v to be removed got %v.\n", test.expr, expected, fs.deleted)
+		}
+	}
+}
+
+// TestInvalidExpr tests that invalid expressions are rejected with the position of the error.
+func TestInvalidExpr(t *testing.T) {
+	tests := []struct {
+		expr     string
+		position string
+	}{
+		{`size > 10MB &&`, "position 15"},
+		{`size > 7d`, "position 6"},
+		{`size >`, "position 7"},
+		{`(size > 1`, "position 10"},
+		{`owner == "root"`, "position 1"},
+		{`name.matches("[")`, "position 14"},
+		{`size.matches("x")`, "position 6"},
+		{`name == "x`, "position 9"},
+		{`size > 10XB`, "position 8"},
+		{`size`, "position 1"},
+		{`size > 1 # 2`, "position 10"},
+		{``, "position 1"},
+	}
+
+	for _, test := range tests {
+		_, err := compileExpr(test.expr)
+		if err == nil {
+			t.Errorf("compileExpr(%q) should return an error", test.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.position) {
+			t.Errorf("compileExpr(%q) = %q, expected error at %s", test.expr, err, test.position)
+		}
+	}
+}
+
+// TestScrubRejectsInvalidExpr tests that invalid expressions are reported before any file is touched.
+func TestScrubRejectsInvalidExpr(t *testing.T) {
+	fs := &mockedFs{files: []os.FileInfo{mockedFileInfo{name: "file.log", size: 100}}}
+
+	c := &TomlConfig{Directories: []directory{{
+		Path: t.TempDir(),
+		Strategies: []StrategyConfig{
+			{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeDelete},
+			{Type: StrategyTypeExpr, Limit: "size >", Action: ActionTypeDelete},
+		},
+	}}}
+
+	err := New(c, fs, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err == nil {
+		t.Errorf("expected an error for an invalid expression")
+	}
+	if len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be removed got %v.\n", fs.deleted)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 )
 
 // Filesystem represents the minimal fs implementation we expect.
@@ -11,11 +12,35 @@ type Filesystem interface {
 	Name(file os.FileInfo) string
 	FullPath(file os.FileInfo, dir string) string
 	Remove(path string) error
+	Rename(oldpath, newpath string) error
+	Link(oldname, newname string) error
+	MkdirAll(path string, perm os.FileMode) error
 	Open(name string) (*os.File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
 	Create(name string) (*os.File, error)
 	Stat(name string) (os.FileInfo, error)
 	ListFiles(path string) ([]os.FileInfo, error)
 	Ext(file os.FileInfo) string
+	Usage(path string) (DiskUsage, error)
+	Times(file os.FileInfo, dir string) (FileTimes, error)
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+}
+
+// FileTimes holds all timestamps of a file. Timestamps that are not supported by
+// the platform or filesystem are left as zero values.
+type FileTimes struct {
+	ModTime    time.Time
+	AccessTime time.Time
+	ChangeTime time.Time
+	BirthTime  time.Time
+}
+
+// DiskUsage holds the capacity of the filesystem a path is stored on.
+type DiskUsage struct {
+	Total uint64
+	Free  uint64
 }
 
 // OSFilesystem proxies calls to the underlying os and file library calls.
@@ -37,11 +62,31 @@ func (fs OSFilesystem) Remove(path string) error {
 	return os.Remove(path)
 }
 
+// Rename moves a file to a new path.
+func (fs OSFilesystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Link creates newname as a hard link to oldname. It fails if newname already exists.
+func (fs OSFilesystem) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// MkdirAll creates a directory and all missing parents.
+func (fs OSFilesystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
 // Open reads a file from the filesystem.
 func (fs OSFilesystem) Open(name string) (*os.File, error) {
 	return os.Open(name)
 }
 
+// OpenFile opens a file using the given flags and permissions.
+func (fs OSFilesystem) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
 // Create creates a file on the filesystem.
 func (fs OSFilesystem) Create(name string) (*os.File, error) {
 	return os.Create(name)
@@ -52,6 +97,21 @@ func (fs OSFilesystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
 
+// Chtimes changes the access and modification times of a file.
+func (fs OSFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Chmod changes the mode of a file.
+func (fs OSFilesystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Chown changes the numeric uid and gid of a file.
+func (fs OSFilesystem) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
 // Ext returns a file's extension.
 func (fs OSFilesystem) Ext(file os.FileInfo) string {
 	return path.Ext(file.Name())
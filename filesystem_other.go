@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package scrubber
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Usage is not supported on this platform.
+func (fs OSFilesystem) Usage(path string) (DiskUsage, error) {
+	return DiskUsage{}, fmt.Errorf("disk usage is not supported on %s", runtime.GOOS)
+}
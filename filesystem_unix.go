@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package scrubber
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Usage returns the capacity of the filesystem path is stored on.
+func (fs OSFilesystem) Usage(path string) (DiskUsage, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return DiskUsage{}, fmt.Errorf("failed to stat filesystem of %s: %s", path, err)
+	}
+
+	return DiskUsage{
+		Total: stat.Blocks * uint64(stat.Bsize),
+		Free:  stat.Bavail * uint64(stat.Bsize),
+	}, nil
+}
@@ -0,0 +1,31 @@
+package scrubber
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// getDiskFreeSpaceEx is used to query the capacity of a volume.
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// Usage returns the capacity of the filesystem path is stored on.
+func (fs OSFilesystem) Usage(path string) (DiskUsage, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+
+	var free, total, totalFree uint64
+	r, _, err := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if r == 0 {
+		return DiskUsage{}, fmt.Errorf("failed to stat filesystem of %s: %s", path, err)
+	}
+
+	return DiskUsage{Total: total, Free: free}, nil
+}
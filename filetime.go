@@ -0,0 +1,153 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// AgeBasis defines which timestamp of a file its age is measured from.
+type AgeBasis string
+
+const (
+	// AgeBasisModTime measures the age from the last modification.
+	AgeBasisModTime AgeBasis = "mtime"
+	// AgeBasisAccessTime measures the age from the last access.
+	AgeBasisAccessTime AgeBasis = "atime"
+	// AgeBasisChangeTime measures the age from the last change of the file's metadata.
+	AgeBasisChangeTime AgeBasis = "ctime"
+	// AgeBasisBirthTime measures the age from the creation of the file.
+	AgeBasisBirthTime AgeBasis = "btime"
+)
+
+// fileTimeFn is the function that determines the point in time a file is dated by.
+type fileTimeFn func(file os.FileInfo) (time.Time, error)
+
+// datedFile is a file combined with the time it is dated by.
+type datedFile struct {
+	os.FileInfo
+	time time.Time
+}
+
+// nameTime extracts a timestamp that is embedded in a file's name.
+type nameTime struct {
+	pattern *regexp.Regexp
+	layout  string
+}
+
+// newFileTimeFn returns a fileTimeFn that parses the time from the file name if a
+// pattern is set and uses the modification time otherwise.
+func newFileTimeFn(pattern, layout string) (fileTimeFn, error) {
+	if pattern == "" {
+		return modTime, nil
+	}
+
+	n, err := newNameTime(pattern, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.parse, nil
+}
+
+// newBasisTimeFn returns a fileTimeFn that reads the timestamp defined by basis from fs.
+func newBasisTimeFn(basis AgeBasis, fs Filesystem, dir string) (fileTimeFn, error) {
+	var pick func(times FileTimes) time.Time
+	switch basis {
+	case AgeBasisModTime:
+		pick = func(times FileTimes) time.Time { return times.ModTime }
+	case AgeBasisAccessTime:
+		pick = func(times FileTimes) time.Time { return times.AccessTime }
+	case AgeBasisChangeTime:
+		pick = func(times FileTimes) time.Time { return times.ChangeTime }
+	case AgeBasisBirthTime:
+		pick = func(times FileTimes) time.Time { return times.BirthTime }
+	default:
+		return nil, fmt.Errorf("unknown age_basis %q", basis)
+	}
+
+	return func(file os.FileInfo) (time.Time, error) {
+		times, err := fs.Times(file, dir)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t := pick(times)
+		if t.IsZero() {
+			return time.Time{}, fmt.Errorf("%s is not available for file %s", basis, file.Name())
+		}
+		return t, nil
+	}, nil
+}
+
+// modTime returns the modification time of a file.
+func modTime(file os.FileInfo) (time.Time, error) {
+	return file.ModTime(), nil
+}
+
+// newNameTime returns a pointer to a nameTime. The first capture group of pattern
+// (or the whole match if there is none) is parsed using the Go time layout.
+func newNameTime(pattern, layout string) (*nameTime, error) {
+	if layout == "" {
+		return nil, fmt.Errorf("name_time_layout is required if a name_time_pattern is set")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name_time_pattern %q: %s", pattern, err)
+	}
+
+	return &nameTime{re, layout}, nil
+}
+
+// parse returns the timestamp embedded in the name of file.
+func (n nameTime) parse(file os.FileInfo) (time.Time, error) {
+	match := n.pattern.FindStringSubmatch(file.Name())
+	if match == nil {
+		return time.Time{}, fmt.Errorf("file name %s does not match %s", file.Name(), n.pattern)
+	}
+
+	value := match[0]
+	if len(match) > 1 {
+		value = match[1]
+	}
+
+	t, err := time.ParseInLocation(n.layout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time from file name %s: %s", file.Name(), err)
+	}
+
+	return t, nil
+}
+
+// fileTime returns the fileTimeFn of a strategy. A name_time_pattern defined on the
+// strategy takes precedence over the one defined on its directory.
+func (s Strategy) fileTime() (fileTimeFn, error) {
+	if s.c.NameTimePattern != "" {
+		return newFileTimeFn(s.c.NameTimePattern, s.c.NameTimeLayout)
+	}
+	return newFileTimeFn(s.dir.NameTimePattern, s.dir.NameTimeLayout)
+}
+
+// sortByTime dates all files and sorts them newest first. Files that cannot be
+// dated are logged and returned separately.
+func sortByTime(files []os.FileInfo, fileTime fileTimeFn, log Logger) ([]datedFile, []os.FileInfo) {
+	var skipped []os.FileInfo
+	dated := make([]datedFile, 0, len(files))
+	for _, file := range files {
+		t, err := fileTime(file)
+		if err != nil {
+			log.Printf("Skipping file %s: %s", file.Name(), err)
+			skipped = append(skipped, file)
+			continue
+		}
+		dated = append(dated, datedFile{file, t})
+	}
+
+	slices.SortStableFunc(dated, func(i, j datedFile) int {
+		return j.time.Compare(i.time)
+	})
+
+	return dated, skipped
+}
@@ -0,0 +1,112 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestNameTimeParser checks if timestamps are parsed from file names correctly.
+func TestNameTimeParser(t *testing.T) {
+	testNames := []struct {
+		pattern  string
+		layout   string
+		name     string
+		expected time.Time
+	}{
+		{`app-(\d{4}-\d{2}-\d{2})\.log`, "2006-01-02", "app-2023-06-15.log", time.Date(2023, 6, 15, 0, 0, 0, 0, time.Local)},
+		{`\d{8}T\d{4}`, "20060102T1504", "dump-20230615T1230.sql", time.Date(2023, 6, 15, 12, 30, 0, 0, time.Local)},
+	}
+
+	for _, table := range testNames {
+		fileTime, err := newFileTimeFn(table.pattern, table.layout)
+		if err != nil {
+			t.Fatalf("newFileTimeFn(%q) got unexpected error %q", table.pattern, err)
+		}
+		got, err := fileTime(mockedFileInfo{name: table.name})
+		if err != nil {
+			t.Errorf("parse(%q) got unexpected error %q", table.name, err)
+		}
+		if !got.Equal(table.expected) {
+			t.Errorf("parse(%q) = %s, expected %s", table.name, got, table.expected)
+		}
+	}
+}
+
+// TestInvalidNameTime tests that invalid patterns and unmatched names are being rejected.
+func TestInvalidNameTime(t *testing.T) {
+	if _, err := newFileTimeFn(`(`, "2006"); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+	if _, err := newFileTimeFn(`\d+`, ""); err == nil {
+		t.Errorf("expected an error for a missing layout")
+	}
+
+	fileTime, _ := newFileTimeFn(`app-(\d{4})`, "2006")
+	if _, err := fileTime(mockedFileInfo{name: "other.log"}); err == nil {
+		t.Errorf("expected an error for an unmatched file name")
+	}
+}
+
+// TestSortFilesNameTime tests that keep_latest ordering uses the time from the file name.
+func TestSortFilesNameTime(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "app-2023-06-13.log", modTime: time.Now()},
+		mockedFileInfo{name: "app-2023-06-15.log", modTime: time.Now().AddDate(-1, 0, 0)},
+		mockedFileInfo{name: "unrelated.log", modTime: time.Now()},
+		mockedFileInfo{name: "app-2023-06-14.log", modTime: time.Now().AddDate(-2, 0, 0)},
+	}
+
+	d := directory{Path: testPath, NameTimePattern: `app-(\d{4}-\d{2}-\d{2})\.log`, NameTimeLayout: "2006-01-02"}
+	s := New(&TomlConfig{}, &mockedFs{}, log.New(ioutil.Discard, "", 0), false)
+
+	sorted, err := s.sortFiles(&d, files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	sorted = ApplyKeepLatest(sorted, 1)
+
+	if len(sorted) != 2 || sorted[0].Name() != "app-2023-06-14.log" || sorted[1].Name() != "app-2023-06-13.log" {
+		t.Errorf("expected \"app-2023-06-14.log\" and \"app-2023-06-13.log\" to remain got %v.\n", sorted)
+	}
+}
+
+// TestOSFilesystemTimes tests that the OSFilesystem reads all timestamps of a real file.
+func TestOSFilesystemTimes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("access and change times are only tested on linux")
+	}
+
+	dir := t.TempDir()
+	err := os.WriteFile(dir+"/file.log", []byte("log"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessTime := time.Now().AddDate(0, 0, -3).Truncate(time.Second)
+	err = os.Chtimes(dir+"/file.log", accessTime, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := OSFilesystem{}
+	info, err := fs.Stat(dir + "/file.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	times, err := fs.Times(info, dir)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+	if !times.AccessTime.Equal(accessTime) {
+		t.Errorf("expected access time %s, got %s", accessTime, times.AccessTime)
+	}
+	if times.ChangeTime.IsZero() {
+		t.Errorf("expected a change time to be set")
+	}
+}
@@ -0,0 +1,89 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// freeStrategy represents the action of cleaning up the oldest files as soon as
+// the free space on the filesystem drops below a low watermark.
+type freeStrategy struct {
+	Strategy
+	fs Filesystem
+}
+
+// newFreeStrategy returns a new freeStrategy.
+func newFreeStrategy(c *StrategyConfig, dir *directory, fs Filesystem, action Action, log Logger) *freeStrategy {
+	return &freeStrategy{Strategy{c, dir, action, log}, fs}
+}
+
+// Select selects the oldest files until the high watermark is reached again.
+//
+// The limit defines the low watermark that triggers the cleanup, the target the
+// high watermark that has to be reached. If no target is set, the limit is used.
+func (s freeStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	usage, err := s.fs.Usage(s.dir.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	low, err := parseWatermark(s.c.Limit, usage.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	high := low
+	if s.c.Target != "" {
+		high, err = parseWatermark(s.c.Target, usage.Total)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if high < low {
+		return nil, fmt.Errorf("target has to be greater than or equal to the limit")
+	}
+
+	evict := make(map[string]bool)
+	if usage.Free < low {
+		s.log.Printf("[Free] Only %d of %d bytes are free on %s, freeing up to %d bytes", usage.Free, usage.Total, s.dir.Path, high)
+
+		oldestFirst := slices.Clone(files)
+		sortNewestFirst(oldestFirst)
+		slices.Reverse(oldestFirst)
+
+		free := usage.Free
+		for _, file := range oldestFirst {
+			if free >= high {
+				break
+			}
+			evict[file.Name()] = true
+			free += uint64(file.Size())
+		}
+	}
+
+	return func(file os.FileInfo) bool {
+		return evict[file.Name()]
+	}, nil
+}
+
+// parseWatermark turns a size like 10GB or a percentage like 15% of total into bytes.
+func parseWatermark(text string, total uint64) (uint64, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasSuffix(text, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(text, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, fmt.Errorf("invalid percentage %q passed to FreeStrategy", text)
+		}
+		return uint64(float64(total) * percent / 100), nil
+	}
+
+	size, err := parseSize([]byte(text))
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(size), nil
+}
@@ -0,0 +1,95 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestFree tests that the oldest files are being deleted until the high watermark is reached.
+func TestFree(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "dayold", size: 10, modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "weekold", size: 10, modTime: time.Now().AddDate(0, 0, -7)},
+		mockedFileInfo{name: "yearold", size: 10, modTime: time.Now().AddDate(-1, 0, 0)},
+	}
+
+	fs := &mockedFs{usage: DiskUsage{Total: 100, Free: 5}}
+
+	c := StrategyConfig{Type: StrategyTypeFree, Limit: "10%", Target: "25b", Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newFreeStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 2 || fs.deleted[0] != testPath+"/weekold" || fs.deleted[1] != testPath+"/yearold" {
+		t.Errorf("expected \"weekold\" and \"yearold\" to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestFreeAboveLimit tests that no files are touched while enough disk space is available.
+func TestFreeAboveLimit(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "dayold", size: 10, modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "yearold", size: 10, modTime: time.Now().AddDate(-1, 0, 0)},
+	}
+
+	fs := &mockedFs{usage: DiskUsage{Total: 100, Free: 20}}
+
+	c := StrategyConfig{Type: StrategyTypeFree, Limit: "15%", Target: "50%", Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newFreeStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestWatermarkParser checks if watermarks are turned into bytes correctly.
+func TestWatermarkParser(t *testing.T) {
+	testWatermarks := []struct {
+		test     string
+		expected uint64
+	}{
+		{"15%", 150},
+		{"100%", 1000},
+		{"0.5%", 5},
+		{"10b", 10},
+		{"1KB", 1024},
+	}
+
+	for _, table := range testWatermarks {
+		got, err := parseWatermark(table.test, 1000)
+		if err != nil {
+			t.Errorf("parseWatermark(%q) got unexpected error %q", table.test, err)
+		}
+		if got != table.expected {
+			t.Errorf("parseWatermark(%q) = %d, expected %d", table.test, got, table.expected)
+		}
+	}
+
+	for _, test := range []string{"101%", "-1%", "x%", "", "2x"} {
+		_, err := parseWatermark(test, 1000)
+		if err == nil {
+			t.Errorf("parseWatermark(%q) should return an error", test)
+		}
+	}
+}
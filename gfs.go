@@ -0,0 +1,98 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// gfsPeriod maps a point in time to the bucket it belongs to.
+type gfsPeriod func(t time.Time) string
+
+// gfsPeriods are all supported grandfather-father-son buckets.
+var gfsPeriods = map[string]gfsPeriod{
+	"daily":   func(t time.Time) string { return t.Format("2006-01-02") },
+	"weekly":  func(t time.Time) string { y, w := t.ISOWeek(); return fmt.Sprintf("%d-%02d", y, w) },
+	"monthly": func(t time.Time) string { return t.Format("2006-01") },
+	"yearly":  func(t time.Time) string { return t.Format("2006") },
+}
+
+// gfsStrategy represents the action of cleaning up all files that are not part
+// of a grandfather-father-son backup rotation.
+type gfsStrategy struct {
+	Strategy
+}
+
+// newGFSStrategy returns a new gfsStrategy.
+func newGFSStrategy(c *StrategyConfig, dir *directory, action Action, log Logger) *gfsStrategy {
+	return &gfsStrategy{Strategy{c, dir, action, log}}
+}
+
+// Select selects all files but the newest file of each of the last n days, weeks,
+// months and years.
+//
+// Like in most backup tools, the last n periods are the n most recent periods
+// that contain at least one file.
+func (s gfsStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	keepCounts := map[string]int{
+		"daily":   s.c.KeepDaily,
+		"weekly":  s.c.KeepWeekly,
+		"monthly": s.c.KeepMonthly,
+		"yearly":  s.c.KeepYearly,
+	}
+
+	var total int
+	for _, n := range keepCounts {
+		if n < 0 {
+			return nil, fmt.Errorf("keep_daily, keep_weekly, keep_monthly and keep_yearly cannot be negative")
+		}
+		total += n
+	}
+	if total < 1 {
+		return nil, fmt.Errorf("at least one of keep_daily, keep_weekly, keep_monthly or keep_yearly is required")
+	}
+
+	dated, keep, err := s.datedFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, period := range gfsPeriods {
+		var last string
+		remaining := keepCounts[name]
+		for _, file := range dated {
+			if remaining < 1 {
+				break
+			}
+			bucket := period(file.time)
+			if bucket == last {
+				continue
+			}
+			last = bucket
+			remaining--
+			keep[file.Name()] = true
+		}
+	}
+
+	return func(file os.FileInfo) bool {
+		return !keep[file.Name()]
+	}, nil
+}
+
+// datedFiles returns all files sorted by their time, newest first. Files without
+// a parsable time are returned as files to keep.
+func (s gfsStrategy) datedFiles(files []os.FileInfo) ([]datedFile, map[string]bool, error) {
+	fileTime, err := s.fileTime()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dated, skipped := sortByTime(files, fileTime, s.log)
+
+	keep := make(map[string]bool)
+	for _, file := range skipped {
+		keep[file.Name()] = true
+	}
+
+	return dated, keep, nil
+}
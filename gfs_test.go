@@ -0,0 +1,96 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestGFS tests that the newest file of each day, week, month and year is kept.
+func TestGFS(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.Local)
+	files := []os.FileInfo{
+		mockedFileInfo{name: "today-late", modTime: now},
+		mockedFileInfo{name: "today-early", modTime: now.Add(-2 * time.Hour)},
+		mockedFileInfo{name: "yesterday", modTime: now.AddDate(0, 0, -1)},
+		mockedFileInfo{name: "twodaysago", modTime: now.AddDate(0, 0, -2)},
+		mockedFileInfo{name: "lastmonth", modTime: now.AddDate(0, -1, 0)},
+		mockedFileInfo{name: "twomonthsago", modTime: now.AddDate(0, -2, 0)},
+		mockedFileInfo{name: "lastyear", modTime: now.AddDate(-1, 0, 0)},
+		mockedFileInfo{name: "twoyearsago", modTime: now.AddDate(-2, 0, 0)},
+	}
+
+	fs := &mockedFs{}
+
+	c := StrategyConfig{Type: StrategyTypeGFS, Action: ActionTypeDelete, KeepDaily: 2, KeepMonthly: 2, KeepYearly: 2}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newGFSStrategy(&c, &d, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	slices.Sort(fs.deleted)
+	expected := []string{testPath + "/today-early", testPath + "/twodaysago", testPath + "/twomonthsago", testPath + "/twoyearsago"}
+	if !slices.Equal(fs.deleted, expected) {
+		t.Errorf("expected %v to be removed got %v.\n", expected, fs.deleted)
+	}
+}
+
+// TestGFSNameTime tests that the time can be parsed from the file name and unmatched files are kept.
+func TestGFSNameTime(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "backup-2023-06-15.tar.gz", modTime: time.Now()},
+		mockedFileInfo{name: "backup-2023-06-14.tar.gz", modTime: time.Now()},
+		mockedFileInfo{name: "backup-2023-06-13.tar.gz", modTime: time.Now()},
+		mockedFileInfo{name: "unrelated.tar.gz", modTime: time.Now().AddDate(-5, 0, 0)},
+	}
+
+	fs := &mockedFs{}
+
+	c := StrategyConfig{
+		Type:            StrategyTypeGFS,
+		Action:          ActionTypeDelete,
+		KeepDaily:       2,
+		NameTimePattern: `backup-(\d{4}-\d{2}-\d{2})`,
+		NameTimeLayout:  "2006-01-02",
+	}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newGFSStrategy(&c, &d, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 1 || fs.deleted[0] != testPath+"/backup-2023-06-13.tar.gz" {
+		t.Errorf("expected only \"backup-2023-06-13.tar.gz\" to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestGFSRequiresKeep tests that a gfs strategy without any keep option is rejected.
+func TestGFSRequiresKeep(t *testing.T) {
+	c := StrategyConfig{Type: StrategyTypeGFS, Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	s := newGFSStrategy(&c, &d, newDeleteAction(&d, &mockedFs{}, logger, false), logger)
+
+	_, err := s.Select([]os.FileInfo{mockedFileInfo{name: "file"}})
+	if err == nil {
+		t.Errorf("expected an error for a gfs strategy without keep options")
+	}
+}
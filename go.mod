@@ -3,8 +3,14 @@ module scrubber
 go 1.21.0
 
 require (
+	filippo.io/age v1.2.1
 	github.com/BurntSushi/toml v0.3.0
 	github.com/c2h5oh/datasize v0.0.0-20171227191756-4eba002a5eae
 	github.com/davecgh/go-spew v1.1.1
+	github.com/klauspost/compress v1.17.11
+	github.com/ulikunitz/xz v0.5.12
 	golang.org/x/sync v0.3.0
+	golang.org/x/sys v0.30.0
 )
+
+require golang.org/x/crypto v0.24.0 // indirect
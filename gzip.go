@@ -0,0 +1,77 @@
+package scrubber
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzipAction represents the action of gzipping old files.
+type gzipAction struct {
+	action
+	level int
+	opts  archiveOptions
+}
+
+// newGzipAction returns a pointer to a gzipAction. A level of 0 uses the default compression level.
+func newGzipAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*gzipAction, error) {
+	level := c.Level
+	if level < 0 || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip level %d, expected 1 to 9 or 0 for the default level", c.Level)
+	}
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	opts, err := newArchiveOptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gzipAction{action{dir, fs, log, pretend}, level, opts}, nil
+}
+
+// Perform gzips files that are past a certain age or certain size.
+func (a gzipAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	return a.performArchive(files, check, "gzip", a.gzip)
+}
+
+// gzip creates a .gz file next to filePath. The original name, modification
+// time and absolute path are stored in the gzip header. The path is stored as
+// comment, or in a path file next to the archive if it cannot be represented in Latin-1.
+func (a gzipAction) gzip(filePath string) error {
+	opts := a.opts
+	opts.pathFile = !isLatin1(absPath(filePath))
+	return a.compressFile(filePath, ".gz", opts, func(dst io.Writer, src io.Reader, info os.FileInfo) error {
+		gzipWriter, err := gzip.NewWriterLevel(dst, a.level)
+		if err != nil {
+			return err
+		}
+		gzipWriter.Name = info.Name()
+		gzipWriter.ModTime = info.ModTime()
+		if comment := absPath(filePath); isLatin1(comment) {
+			gzipWriter.Comment = comment
+		}
+
+		_, err = io.Copy(gzipWriter, src)
+		if err != nil {
+			gzipWriter.Close()
+			return err
+		}
+
+		return gzipWriter.Close()
+	}, func(archive io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(archive)
+	})
+}
+
+// isLatin1 reports whether s only contains characters of ISO 8859-1 and can be stored in a gzip header.
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r == 0 || r > 0xff {
+			return false
+		}
+	}
+	return true
+}
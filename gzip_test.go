@@ -0,0 +1,115 @@
+package scrubber
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestGzip tests if the file is gzipped with its metadata and removed correctly.
+func TestGzip(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().AddDate(0, 0, -3).Truncate(time.Second)
+
+	err := os.WriteFile(dir+"/app.log", []byte("line 1\nline 2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chtimes(dir+"/app.log", modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := OSFilesystem{}
+	files, err := fs.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeGzip, Level: 9}
+	d := directory{Path: dir}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a, err := newGzipAction(&c, &d, fs, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSizeStrategy(&c, &d, a, logger)
+	_, err = selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error from process, got %v\n", err)
+	}
+
+	if _, err := os.Stat(dir + "/app.log"); !os.IsNotExist(err) {
+		t.Errorf("expected the original file to be removed, got %v", err)
+	}
+
+	info, err := os.Stat(dir + "/app.log.gz")
+	if err != nil {
+		t.Fatalf("expected app.log.gz to exist, got %v", err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected the gzip file to have mtime %s, got %s", modTime, info.ModTime())
+	}
+
+	f, err := os.Open(dir + "/app.log.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "line 1\nline 2\n" {
+		t.Errorf("unexpected gzip content %q", content)
+	}
+	if r.Name != "app.log" || !r.ModTime.Equal(modTime) {
+		t.Errorf("expected gzip header with name app.log and mtime %s, got %s and %s", modTime, r.Name, r.ModTime)
+	}
+}
+
+// TestGzipPretend tests that no files are touched in pretend mode.
+func TestGzipPretend(t *testing.T) {
+	fs := &mockedFs{}
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "10b", Action: ActionTypeGzip}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a, err := newGzipAction(&c, &d, fs, logger, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSizeStrategy(&c, &d, a, logger)
+	_, err = selectorStrategy{s, a}.process([]os.FileInfo{mockedFileInfo{name: "app.log", size: 20}})
+	if err != nil {
+		t.Errorf("expected no error from process, got %v\n", err)
+	}
+
+	if len(fs.created) != 0 || len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be touched, got %v and %v.\n", fs.created, fs.deleted)
+	}
+}
+
+// TestInvalidGzipLevel tests that invalid compression levels are being rejected.
+func TestInvalidGzipLevel(t *testing.T) {
+	for _, level := range []int{-3, 10} {
+		c := StrategyConfig{Action: ActionTypeGzip, Level: level}
+		_, err := newGzipAction(&c, &directory{Path: testPath}, &mockedFs{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for level %d", level)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// HookConfig holds a hook that runs after all strategies of a directory have finished.
+type HookConfig struct {
+	// Pidfile is the path of a file containing the PID of the process the signal is sent to.
+	Pidfile string
+	// Signal is the name of the signal that is sent to the process, defaults to HUP.
+	Signal string
+	// Command is a command and its arguments that are executed.
+	Command []string
+}
+
+// validate makes sure the hook can be run.
+func (h HookConfig) validate() error {
+	if (h.Pidfile == "") == (len(h.Command) == 0) {
+		return fmt.Errorf("hook requires either a pidfile or a command")
+	}
+	if h.Pidfile != "" {
+		_, err := parseSignal(h.Signal)
+		return err
+	}
+	if h.Signal != "" {
+		return fmt.Errorf("signal can only be used together with a pidfile")
+	}
+	return nil
+}
+
+// parseSignal returns the signal for a name like HUP or SIGHUP. An empty name returns SIGHUP.
+func parseSignal(name string) (os.Signal, error) {
+	if name == "" {
+		name = "HUP"
+	}
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal %q", name)
+	}
+	return sig, nil
+}
+
+// runHooks runs all hooks of a directory if any files were affected by its strategies.
+// All hooks are run even if one of them fails, their errors are returned together.
+func (s Scrubber) runHooks(dir *directory, affected []string) error {
+	if len(dir.Hooks) == 0 || len(affected) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, hook := range dir.Hooks {
+		var err error
+		if hook.Pidfile != "" {
+			err = s.signalHook(hook)
+		} else {
+			err = s.commandHook(hook, dir, affected)
+		}
+		if err != nil {
+			s.log.Printf("[Hook] ERROR: %s", err)
+			errs = append(errs, fmt.Errorf("hook for directory %s failed: %s", dir.Path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// signalHook sends the signal of hook to the process in its pidfile.
+func (s Scrubber) signalHook(hook HookConfig) error {
+	sig, err := parseSignal(hook.Signal)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.fs.Open(hook.Pidfile)
+	if err != nil {
+		return fmt.Errorf("failed to open pidfile: %v", err)
+	}
+	content, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read pidfile: %v", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid < 1 {
+		return fmt.Errorf("invalid PID in pidfile %s", hook.Pidfile)
+	}
+
+	if s.pretend {
+		s.log.Printf("[Hook] PRETEND: Would send %s to process %d", sig, pid)
+		return nil
+	}
+
+	s.log.Printf("[Hook] Sending %s to process %d", sig, pid)
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
+
+// commandHook runs the command of hook. The directory is passed in SCRUBBER_DIR and
+// all affected files in SCRUBBER_FILES, separated by newlines.
+func (s Scrubber) commandHook(hook HookConfig, dir *directory, affected []string) error {
+	if s.pretend {
+		s.log.Printf("[Hook] PRETEND: Would run %s", strings.Join(hook.Command, " "))
+		return nil
+	}
+
+	s.log.Printf("[Hook] Running %s", strings.Join(hook.Command, " "))
+
+	cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"SCRUBBER_DIR="+dir.Path,
+		"SCRUBBER_FILES="+strings.Join(affected, "\n"),
+	)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		s.log.Printf("[Hook] %s", strings.TrimSpace(string(output)))
+	}
+	if err != nil {
+		return fmt.Errorf("command %s failed: %v", hook.Command[0], err)
+	}
+	return nil
+}
+
+// affectedFiles returns the full paths of all files that were passed to a strategy but not returned by it.
+func affectedFiles(fs Filesystem, dir *directory, files, remaining []os.FileInfo) []string {
+	left := make(map[string]bool, len(remaining))
+	for _, file := range remaining {
+		left[file.Name()] = true
+	}
+
+	var affected []string
+	for _, file := range files {
+		if !left[file.Name()] {
+			affected = append(affected, fs.FullPath(file, dir.Path))
+		}
+	}
+	return affected
+}
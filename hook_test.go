@@ -0,0 +1,123 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runScrub runs a size strategy deleting all files larger than one byte in dir with the given hooks.
+func runScrub(t *testing.T, dir string, hooks []HookConfig) error {
+	t.Helper()
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Exclude:    []string{"out"},
+		Strategies: []StrategyConfig{{Type: StrategyTypeSize, Action: ActionTypeDelete, Limit: "1b"}},
+		Hooks:      hooks,
+	}}}
+	return New(c, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+}
+
+// TestCommandHook tests that a command hook receives all affected files.
+func TestCommandHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "big.log"), "big", time.Now())
+	writeTestFile(t, filepath.Join(dir, "small.log"), "s", time.Now())
+	out := filepath.Join(dir, "hook.out")
+
+	err := runScrub(t, dir, []HookConfig{{Command: []string{"sh", "-c", `printf "%s" "$SCRUBBER_FILES" > "$0"`, out}}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	assertContent(t, out, filepath.Join(dir, "big.log"))
+}
+
+// TestFailingHook tests that failing hooks are reported.
+func TestFailingHook(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "big.log"), "big", time.Now())
+
+	err := runScrub(t, dir, []HookConfig{
+		{Pidfile: filepath.Join(dir, "missing.pid")},
+		{Command: []string{filepath.Join(dir, "missing")}},
+	})
+	if err == nil {
+		t.Fatal("expected an error from the hooks")
+	}
+	if n := strings.Count(err.Error(), "hook for directory"); n != 2 {
+		t.Errorf("expected 2 hook errors, got %d: %v", n, err)
+	}
+}
+
+// TestHookWithoutFiles tests that hooks are not run if no files were affected.
+func TestHookWithoutFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "small.log"), "s", time.Now())
+
+	err := runScrub(t, dir, []HookConfig{{Command: []string{filepath.Join(dir, "missing")}}})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+// TestSignalHook tests that the signal is sent to the process in the pidfile.
+func TestSignalHook(t *testing.T) {
+	sig, err := parseSignal("SIGUSR1")
+	if err != nil {
+		t.Skip("USR1 is not supported on this platform")
+	}
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, sig)
+	defer signal.Stop(received)
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "big.log"), "big", time.Now())
+	pidfile := filepath.Join(t.TempDir(), "app.pid")
+	writeTestFile(t, pidfile, strconv.Itoa(os.Getpid())+"\n", time.Now())
+
+	err = runScrub(t, dir, []HookConfig{{Pidfile: pidfile, Signal: "usr1"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the signal to be received")
+	}
+}
+
+// TestInvalidHook tests that invalid hooks are rejected before any file is touched.
+func TestInvalidHook(t *testing.T) {
+	hooks := []HookConfig{
+		{},
+		{Pidfile: "app.pid", Command: []string{"true"}},
+		{Pidfile: "app.pid", Signal: "NOPE"},
+		{Command: []string{"true"}, Signal: "HUP"},
+	}
+	for _, hook := range hooks {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "big.log")
+		writeTestFile(t, path, "big", time.Now())
+
+		err := runScrub(t, dir, []HookConfig{hook})
+		if err == nil {
+			t.Errorf("expected an error for %+v", hook)
+		}
+		assertContent(t, path, "big")
+	}
+}
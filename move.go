@@ -0,0 +1,194 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+)
+
+// moveData is passed to the destination template.
+type moveData struct {
+	// Name is the name of the file.
+	Name string
+	// Dir is the full path of the directory that is being scrubbed.
+	Dir string
+	// DirName is the name of the directory that is being scrubbed.
+	DirName string
+	// ModTime is the modification time of the file.
+	ModTime time.Time
+}
+
+// moveAction represents the action of moving old files to another location.
+type moveAction struct {
+	action
+	destination *template.Template
+	collision   CollisionPolicy
+}
+
+// newMoveAction returns a pointer to a moveAction.
+func newMoveAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*moveAction, error) {
+	if c.Destination == "" {
+		return nil, fmt.Errorf("move action requires a destination")
+	}
+
+	destination, err := template.New("destination").Option("missingkey=error").Parse(c.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination template: %s", err)
+	}
+
+	collision, err := validateCollision(c.Collision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &moveAction{action{dir, fs, log, pretend}, destination, collision}, nil
+}
+
+// Perform moves files that are past a certain age or certain size.
+func (a moveAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Move] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		destination, err := a.destinationPath(file)
+		if err != nil {
+			return files, err
+		}
+
+		if a.pretend {
+			a.log.Printf("[Move] PRETEND: Would move file %s to %s", filename, destination)
+			continue
+		}
+
+		target, skip, err := resolveCollision(a.fs, destination, a.collision)
+		if err != nil {
+			return files, err
+		}
+		if skip {
+			a.log.Printf("[Move] Skipping file %s, %s already exists", filename, destination)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		a.log.Printf("[Move] Moving file %s to %s", filename, target)
+
+		moved, skip, err := moveFile(a.fs, filename, destination, a.collision)
+		if err != nil {
+			a.log.Printf("[Move] ERROR: Failed to move file %s: %s", filename, err)
+			continue
+		}
+		if skip {
+			a.log.Printf("[Move] Skipping file %s, %s has been created in the meantime", filename, destination)
+			newFiles = append(newFiles, file)
+			continue
+		}
+		if moved != target {
+			a.log.Printf("[Move] Moved file %s to %s instead, %s has been created in the meantime", filename, moved, target)
+		}
+	}
+	return newFiles, nil
+}
+
+// destinationPath renders the destination template for file. Relative destinations
+// are resolved against the directory that is being scrubbed.
+func (a moveAction) destinationPath(file os.FileInfo) (string, error) {
+	var destination strings.Builder
+	err := a.destination.Execute(&destination, moveData{
+		Name:    file.Name(),
+		Dir:     a.dir.Path,
+		DirName: filepath.Base(a.dir.Path),
+		ModTime: file.ModTime(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render destination: %s", err)
+	}
+
+	path := destination.String()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(a.dir.Path, path)
+	}
+
+	return path, nil
+}
+
+// moveFile renames src to the path resolveCollision returns for dst. Files that
+// are created at the destination in the meantime are handled by the collision
+// policy as well. If both are on different filesystems, the file is copied to
+// a temporary file next to dst, renamed and removed instead. It returns the
+// path the file has been moved to.
+func moveFile(fs Filesystem, src, dst string, policy CollisionPolicy) (target string, skip bool, err error) {
+	err = fs.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create destination directory: %v", err)
+	}
+
+	target, skip, err = renameNoReplace(fs, src, dst, policy)
+	if err == nil || skip || !errors.Is(err, syscall.EXDEV) {
+		return target, skip, err
+	}
+
+	tmpPath := tempPath(dst)
+	err = copyFile(fs, src, tmpPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	target, skip, err = renameNoReplace(fs, tmpPath, dst, policy)
+	if err != nil || skip {
+		fs.Remove(tmpPath)
+		return "", skip, err
+	}
+
+	return target, false, fs.Remove(src)
+}
+
+// copyFile copies src to dst, syncs it to disk and preserves its mode and
+// modification time. A partial copy is removed.
+func copyFile(fs Filesystem, src, dst string) error {
+	info, err := fs.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	in, err := fs.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer in.Close()
+
+	out, err := fs.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %v", err)
+	}
+
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = syncClose(out)
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = fs.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		fs.Remove(dst)
+		return fmt.Errorf("failed to copy file: %v", err)
+	}
+
+	return nil
+}
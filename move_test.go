@@ -0,0 +1,241 @@
+package scrubber
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// exdevFs is a filesystem that cannot rename files, like a move across devices.
+type exdevFs struct {
+	OSFilesystem
+}
+
+// Rename fails with EXDEV for all files except temporary files, which are created on the destination device.
+func (fs exdevFs) Rename(oldpath, newpath string) error {
+	if isTempFile(oldpath) {
+		return fs.OSFilesystem.Rename(oldpath, newpath)
+	}
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+}
+
+// Link fails with EXDEV for all files except temporary files, just like Rename.
+func (fs exdevFs) Link(oldname, newname string) error {
+	if isTempFile(oldname) {
+		return fs.OSFilesystem.Link(oldname, newname)
+	}
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EXDEV}
+}
+
+// racingFs is a filesystem on which another process creates path right before
+// scrubber links a file to it, after its name has already been resolved.
+type racingFs struct {
+	OSFilesystem
+	path    string
+	content string
+}
+
+// Link creates path with the content of the other process first.
+func (fs *racingFs) Link(oldname, newname string) error {
+	if newname == fs.path && fs.content != "" {
+		err := os.WriteFile(newname, []byte(fs.content), 0644)
+		if err != nil {
+			return err
+		}
+		fs.content = ""
+	}
+	return fs.OSFilesystem.Link(oldname, newname)
+}
+
+// noLinkFs is a filesystem without hard links.
+type noLinkFs struct {
+	OSFilesystem
+}
+
+// Link always fails like on filesystems that don't support hard links.
+func (fs noLinkFs) Link(oldname, newname string) error {
+	return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
+// assertContent checks the content of a file.
+func assertContent(t *testing.T, path, expected string) {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("expected %s to exist, got %v", path, err)
+		return
+	}
+	if string(content) != expected {
+		t.Errorf("expected %s to contain %q, got %q", path, expected, content)
+	}
+}
+
+// TestMove tests that files are moved to the rendered destination.
+func TestMove(t *testing.T) {
+	for _, fs := range []Filesystem{OSFilesystem{}, exdevFs{}, noLinkFs{}} {
+		src := t.TempDir()
+		dst := t.TempDir()
+		modTime := time.Date(2023, 6, 15, 12, 0, 0, 0, time.Local)
+		writeTestFile(t, src+"/app.log", "app", modTime)
+
+		c := StrategyConfig{
+			Type:        StrategyTypeSize,
+			Limit:       "1b",
+			Action:      ActionTypeMove,
+			Destination: dst + `/{{.DirName}}/{{.ModTime.Format "2006/01"}}/{{.Name}}`,
+		}
+		runAction(t, fs, directory{Path: src}, c)
+
+		moved := dst + "/" + filepath.Base(src) + "/2023/06/app.log"
+		assertContent(t, moved, "app")
+
+		info, err := os.Stat(moved)
+		if err == nil && !info.ModTime().Equal(modTime) {
+			t.Errorf("%T: expected mtime %s, got %s", fs, modTime, info.ModTime())
+		}
+
+		if _, err := os.Stat(src + "/app.log"); !os.IsNotExist(err) {
+			t.Errorf("%T: expected the original file to be removed, got %v", fs, err)
+		}
+	}
+}
+
+// TestMoveRelative tests that relative destinations are resolved against the directory.
+func TestMoveRelative(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, src+"/app.log", "app", time.Now())
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeMove, Destination: "archive/{{.Name}}"}
+	runAction(t, OSFilesystem{}, directory{Path: src}, c)
+
+	assertContent(t, src+"/archive/app.log", "app")
+	assertMissing(t, src+"/app.log")
+}
+
+// TestMoveCollision tests that existing destination files are handled using the collision policy.
+func TestMoveCollision(t *testing.T) {
+	tests := []struct {
+		policy    CollisionPolicy
+		expected  map[string]string
+		remaining bool
+	}{
+		{"", map[string]string{"app.log": "old", "app.1.log": "new"}, false},
+		{CollisionSkip, map[string]string{"app.log": "old"}, true},
+		{CollisionOverwrite, map[string]string{"app.log": "new"}, false},
+		{CollisionFail, map[string]string{"app.log": "old"}, true},
+	}
+
+	for _, test := range tests {
+		src := t.TempDir()
+		dst := t.TempDir()
+		writeTestFile(t, src+"/app.log", "new", time.Now())
+		writeTestFile(t, dst+"/app.log", "old", time.Now())
+
+		c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeMove, Destination: dst + "/{{.Name}}", Collision: test.policy}
+
+		fs := OSFilesystem{}
+		files, _ := fs.ListFiles(src)
+		d := directory{Path: src}
+		logger := log.New(ioutil.Discard, "", 0)
+		a, err := newMoveAction(&c, &d, fs, logger, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = selectorStrategy{newSizeStrategy(&c, &d, a, logger), a}.process(files)
+		if (err != nil) != (test.policy == CollisionFail) {
+			t.Errorf("%s: unexpected error %v", test.policy, err)
+		}
+
+		for name, content := range test.expected {
+			assertContent(t, dst+"/"+name, content)
+		}
+		if names := dirNames(t, dst); len(names) != len(test.expected) {
+			t.Errorf("%s: expected %d files in the destination, got %v", test.policy, len(test.expected), names)
+		}
+		if _, err := os.Stat(src + "/app.log"); os.IsNotExist(err) == test.remaining {
+			t.Errorf("%s: expected the original file to remain: %t", test.policy, test.remaining)
+		}
+	}
+}
+
+// TestMoveRace tests that files created at the destination while a file is moved are never replaced.
+func TestMoveRace(t *testing.T) {
+	tests := []struct {
+		policy   CollisionPolicy
+		expected map[string]string
+	}{
+		{"", map[string]string{"app.log": "other", "app.1.log": "new"}},
+		{CollisionSkip, map[string]string{"app.log": "other"}},
+		{CollisionFail, map[string]string{"app.log": "other"}},
+	}
+
+	for _, test := range tests {
+		src := t.TempDir()
+		dst := t.TempDir()
+		writeTestFile(t, src+"/app.log", "new", time.Now())
+
+		fs := &racingFs{path: dst + "/app.log", content: "other"}
+		runAction(t, fs, directory{Path: src}, StrategyConfig{Action: ActionTypeMove, Limit: "1b", Destination: dst + "/{{.Name}}", Collision: test.policy})
+
+		for name, content := range test.expected {
+			assertContent(t, dst+"/"+name, content)
+		}
+		if names := dirNames(t, dst); len(names) != len(test.expected) {
+			t.Errorf("%s: expected %d files in the destination, got %v", test.policy, len(test.expected), names)
+		}
+		if _, ok := test.expected["app.1.log"]; !ok {
+			assertContent(t, src+"/app.log", "new")
+		}
+	}
+}
+
+// dyingFs is a filesystem on which the process dies while a file is renamed or linked.
+type dyingFs struct {
+	OSFilesystem
+}
+
+// Rename panics.
+func (fs dyingFs) Rename(oldpath, newpath string) error { panic("crash") }
+
+// Link panics.
+func (fs dyingFs) Link(oldname, newname string) error { panic("crash") }
+
+// TestMoveCrash tests that a move that is interrupted leaves nothing behind at the destination.
+func TestMoveCrash(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	writeTestFile(t, src+"/app.log", "app", time.Now())
+
+	func() {
+		defer func() { recover() }()
+		runAction(t, dyingFs{}, directory{Path: src}, StrategyConfig{Action: ActionTypeMove, Limit: "1b", Destination: dst + "/{{.Name}}"})
+	}()
+
+	if names := dirNames(t, dst); len(names) != 0 {
+		t.Errorf("expected the destination to be empty, got %v", names)
+	}
+	assertContent(t, src+"/app.log", "app")
+}
+
+// TestInvalidMove tests that invalid move configurations are being rejected.
+func TestInvalidMove(t *testing.T) {
+	configs := []StrategyConfig{
+		{},
+		{Destination: "/mnt/{{.Name"},
+		{Destination: "/mnt/{{.Name}}", Collision: "rename"},
+	}
+
+	for _, c := range configs {
+		c := c
+		_, err := newMoveAction(&c, &directory{Path: testPath}, &mockedFs{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package scrubber
+
+import "os"
+
+// ownershipSupported is true if files have a numeric owner that can be preserved.
+const ownershipSupported = false
+
+// fileOwner returns the numeric user and group ID of a file, which is not supported on this platform.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
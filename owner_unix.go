@@ -0,0 +1,20 @@
+//go:build unix
+
+package scrubber
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownershipSupported is true if files have a numeric owner that can be preserved.
+const ownershipSupported = true
+
+// fileOwner returns the numeric user and group ID of a file.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
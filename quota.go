@@ -0,0 +1,87 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// quotaStrategy represents the action of cleaning up the oldest files until a
+// directory fits into a total size budget.
+type quotaStrategy struct {
+	Strategy
+	fs    Filesystem
+	limit int64
+}
+
+// newQuotaStrategy returns a new quotaStrategy.
+func newQuotaStrategy(c *StrategyConfig, dir *directory, fs Filesystem, action Action, log Logger) *quotaStrategy {
+	return &quotaStrategy{Strategy{c, dir, action, log}, fs, 0}
+}
+
+// Select selects the oldest files until the directory is within its size budget.
+//
+// The total size includes all files of the directory that match the include and
+// exclude rules, even the ones protected by keep_latest. Only the files passed in
+// are considered for cleanup.
+func (s quotaStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	err := s.unmarshalText([]byte(s.c.Limit))
+	if err != nil {
+		return nil, err
+	}
+	if s.limit <= 0 {
+		return nil, fmt.Errorf("quota limit has to be greater than 0")
+	}
+
+	total, err := s.totalSize()
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Printf("[Quota] Directory %s uses %d of %d bytes", s.dir.Path, total, s.limit)
+
+	oldestFirst := slices.Clone(files)
+	sortNewestFirst(oldestFirst)
+	slices.Reverse(oldestFirst)
+
+	evict := make(map[string]bool)
+	for _, file := range oldestFirst {
+		if total <= s.limit {
+			break
+		}
+		evict[file.Name()] = true
+		total -= file.Size()
+	}
+
+	return func(file os.FileInfo) bool {
+		return evict[file.Name()]
+	}, nil
+}
+
+// totalSize returns the combined size of all files in the directory.
+func (s quotaStrategy) totalSize() (int64, error) {
+	scanner := newDirectoryScanner(s.dir, s.fs)
+	files, err := scanner.getFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, file := range scanner.filterFiles(files) {
+		total += file.Size()
+	}
+
+	return total, nil
+}
+
+// unmarshalText turns a string representation of a size budget into bytes.
+func (s *quotaStrategy) unmarshalText(text []byte) error {
+	limit, err := parseSize(text)
+	if err != nil {
+		return err
+	}
+
+	s.limit = limit
+
+	return nil
+}
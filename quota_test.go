@@ -0,0 +1,93 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestQuota tests that the oldest files are being deleted until the directory fits into its budget.
+func TestQuota(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "hourold.log", size: 50, modTime: time.Now().Add(-1 * time.Hour)},
+		mockedFileInfo{name: "dayold.log", size: 50, modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "weekold.log", size: 50, modTime: time.Now().AddDate(0, 0, -7)},
+		mockedFileInfo{name: "yearold.log", size: 50, modTime: time.Now().AddDate(-1, 0, 0)},
+	}
+
+	fs := &mockedFs{files: files}
+
+	c := StrategyConfig{Type: StrategyTypeQuota, Limit: "120b", Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newQuotaStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 2 || fs.deleted[0] != testPath+"/weekold.log" || fs.deleted[1] != testPath+"/yearold.log" {
+		t.Errorf("expected \"weekold.log\" and \"yearold.log\" to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestQuotaKeepLatest tests that files protected by keep_latest count towards the budget but are never deleted.
+func TestQuotaKeepLatest(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "hourold.log", size: 100, modTime: time.Now().Add(-1 * time.Hour)},
+		mockedFileInfo{name: "dayold.log", size: 100, modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "weekold.log", size: 10, modTime: time.Now().AddDate(0, 0, -7)},
+	}
+
+	fs := &mockedFs{files: files}
+
+	c := StrategyConfig{Type: StrategyTypeQuota, Limit: "50b", Action: ActionTypeDelete}
+	d := directory{Path: testPath, KeepLatest: 2}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newQuotaStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(ApplyKeepLatest(files, d.KeepLatest))
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 1 || fs.deleted[0] != testPath+"/weekold.log" {
+		t.Errorf("expected only \"weekold.log\" to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestQuotaWithinBudget tests that no files are touched if the directory is within its budget.
+func TestQuotaWithinBudget(t *testing.T) {
+	files := []os.FileInfo{
+		mockedFileInfo{name: "dayold.log", size: 50, modTime: time.Now().AddDate(0, 0, -1)},
+		mockedFileInfo{name: "weekold.log", size: 50, modTime: time.Now().AddDate(0, 0, -7)},
+	}
+
+	fs := &mockedFs{files: files}
+
+	c := StrategyConfig{Type: StrategyTypeQuota, Limit: "1KB", Action: ActionTypeDelete}
+	d := directory{Path: testPath}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a := newDeleteAction(&d, fs, logger, false)
+	s := newQuotaStrategy(&c, &d, fs, a, logger)
+
+	_, err := selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be removed got %v.\n", fs.deleted)
+	}
+}
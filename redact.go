@@ -0,0 +1,266 @@
+package scrubber
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// defaultRedactReplacement replaces matches if no replacement is configured.
+const defaultRedactReplacement = "[REDACTED]"
+
+// RedactReplacement is a custom regular expression whose matches are replaced.
+type RedactReplacement struct {
+	// Name identifies the replacement in the log, defaults to the pattern.
+	Name string
+	// Pattern is the regular expression matches are searched with.
+	Pattern string
+	// Replace replaces every match and may refer to capture groups like $1. Defaults to [REDACTED].
+	Replace string
+}
+
+// redactPattern is a compiled pattern of the redact action.
+type redactPattern struct {
+	name    string
+	re      *regexp.Regexp
+	replace string
+	// valid filters matches that only look like sensitive data, nil accepts all matches.
+	valid func(match string) bool
+}
+
+// redactBuiltins holds the patterns that can be enabled by name.
+var redactBuiltins = map[string]redactPattern{
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"ipv4": {
+		re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+	},
+	// ipv6 matches whole words that contain a colon, including a dotted IPv4 tail and a zone,
+	// so isIPv6 never accepts a part of a longer word or address.
+	"ipv6": {
+		re:    regexp.MustCompile(`\w*:[\w:]*(?:\.\w+)*(?:%[\w.-]*\w)?`),
+		valid: isIPv6,
+	},
+	"creditcard": {
+		re:    regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid: luhnValid,
+	},
+}
+
+// isIPv6 reports whether s is an IPv6 address, optionally with a zone.
+func isIPv6(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return s != "::" && err == nil && addr.Is6()
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum used by credit card numbers.
+func luhnValid(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		digit := int(s[i] - '0')
+		if n%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		n++
+	}
+	return sum%10 == 0
+}
+
+// apply replaces all valid matches in line and returns the new line and the number of replacements.
+func (p redactPattern) apply(line string) (string, int) {
+	matches := p.re.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line, 0
+	}
+
+	var out []byte
+	var last, n int
+	for _, m := range matches {
+		if p.valid != nil && !p.valid(line[m[0]:m[1]]) {
+			continue
+		}
+		out = append(out, line[last:m[0]]...)
+		out = p.re.ExpandString(out, p.replace, line, m)
+		last = m[1]
+		n++
+	}
+	if n == 0 {
+		return line, 0
+	}
+
+	out = append(out, line[last:]...)
+	return string(out), n
+}
+
+// redactAction represents the action of replacing sensitive data in files.
+type redactAction struct {
+	action
+	patterns []redactPattern
+}
+
+// newRedactAction returns a pointer to a redactAction.
+func newRedactAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*redactAction, error) {
+	var patterns []redactPattern
+	for _, name := range c.Redact {
+		p, ok := redactBuiltins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redact pattern %q, expected email, ipv4, ipv6 or creditcard", name)
+		}
+		p.name = name
+		p.replace = defaultRedactReplacement
+		patterns = append(patterns, p)
+	}
+
+	for _, r := range c.Replacements {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil || r.Pattern == "" {
+			return nil, fmt.Errorf("invalid replacement pattern %q", r.Pattern)
+		}
+		p := redactPattern{name: r.Name, re: re, replace: r.Replace}
+		if p.name == "" {
+			p.name = r.Pattern
+		}
+		if p.replace == "" {
+			p.replace = defaultRedactReplacement
+		}
+		patterns = append(patterns, p)
+	}
+
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("redact action requires at least one pattern or replacement")
+	}
+
+	return &redactAction{action{dir, fs, log, pretend}, patterns}, nil
+}
+
+// Perform redacts files that are past a certain age or certain size.
+func (a redactAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Redact] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		if a.pretend {
+			a.log.Printf("[Redact] PRETEND: Would redact file %s", filename)
+			continue
+		}
+
+		a.log.Printf("[Redact] Redacting file %s", filename)
+
+		// Redacted files stay in place, so they are passed on to the next strategy.
+		newFiles = append(newFiles, file)
+
+		counts, err := a.redact(filename)
+		if err != nil {
+			a.log.Printf("[Redact] ERROR: Failed to redact file %s: %s", filename, err)
+			continue
+		}
+
+		var report []string
+		for i, p := range a.patterns {
+			report = append(report, fmt.Sprintf("%s=%d", p.name, counts[i]))
+		}
+		a.log.Printf("[Redact] Replacements in file %s: %s", filename, strings.Join(report, ", "))
+	}
+	return newFiles, nil
+}
+
+// redact streams filename line by line into a temporary file and replaces the original
+// with it once it has been written completely. The modification time is preserved. It
+// returns the number of replacements per pattern.
+func (a redactAction) redact(filename string) ([]int, error) {
+	info, err := a.fs.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	in, err := a.fs.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer in.Close()
+
+	tmpPath := tempPath(filename)
+	out, err := a.fs.Create(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+
+	counts, err := a.copyRedacted(out, in)
+	if err == nil && !slices.ContainsFunc(counts, func(n int) bool { return n > 0 }) {
+		// Files without sensitive data are left untouched.
+		out.Close()
+		a.fs.Remove(tmpPath)
+		return counts, nil
+	}
+	if err == nil {
+		err = out.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = a.fs.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = a.fs.Rename(tmpPath, filename)
+	}
+	if err != nil {
+		a.fs.Remove(tmpPath)
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// copyRedacted copies r to w line by line and applies all patterns to every line.
+func (a redactAction) copyRedacted(w io.Writer, r io.Reader) ([]int, error) {
+	counts := make([]int, len(a.patterns))
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(w)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			for i, p := range a.patterns {
+				var n int
+				line, n = p.apply(line)
+				counts[i] += n
+			}
+			_, werr := writer.WriteString(line)
+			if werr != nil {
+				return nil, werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, writer.Flush()
+}
@@ -0,0 +1,96 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRedactBuiltins tests the built-in patterns.
+func TestRedactBuiltins(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		line     string
+		expected string
+	}{
+		{"email", "login by jane.doe+test@example.co.uk failed", "login by [REDACTED] failed"},
+		{"ipv4", "GET / from 192.168.0.17 at 10:15:30", "GET / from [REDACTED] at 10:15:30"},
+		{"ipv4", "version 1.2.3.400", "version 1.2.3.400"},
+		{"ipv6", "from 2001:db8::8a2e:370:7334 and ::1", "from [REDACTED] and [REDACTED]"},
+		{"ipv6", "at 10:15:30 in std::vector", "at 10:15:30 in std::vector"},
+		{"ipv6", "id x::1 and fe80::1g, [fe80::1]:80", "id x::1 and fe80::1g, [[REDACTED]]:80"},
+		{"ipv6", "from ::ffff:192.168.1.1 and 64:ff9b::10.0.0.1.", "from [REDACTED] and [REDACTED]."},
+		{"ipv6", "via fe80::1%eth0, fe80::2%en0.1 or ::1%", "via [REDACTED], [REDACTED] or [REDACTED]%"},
+		{"ipv6", "at 10:15:30.123 and ::ffff:1.2.3", "at 10:15:30.123 and ::ffff:1.2.3"},
+		{"creditcard", "paid with 4111 1111 1111 1111", "paid with [REDACTED]"},
+		{"creditcard", "order 1234567890123", "order 1234567890123"},
+		{"creditcard", "id x4111111111111111 and 4111111111111111x", "id x4111111111111111 and 4111111111111111x"},
+	}
+
+	for _, test := range tests {
+		p := redactBuiltins[test.pattern]
+		p.replace = defaultRedactReplacement
+		actual, _ := p.apply(test.line)
+		if actual != test.expected {
+			t.Errorf("expected %s to turn %q into %q, got %q", test.pattern, test.line, test.expected, actual)
+		}
+	}
+}
+
+// TestRedact tests that files are rewritten with all replacements and keep their modification time.
+func TestRedact(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	long := strings.Repeat("x", 100000)
+	writeTestFile(t, path, "user=jane@example.com ip=10.0.0.1\nuser=bob@example.com token=abc123\n"+long+"\nend", modTime)
+
+	remaining, out := runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{
+		Action: ActionTypeRedact,
+		Limit:  "1b",
+		Redact: []string{"email", "ipv4"},
+		Replacements: []RedactReplacement{
+			{Name: "token", Pattern: `token=(\w{3})\w+`, Replace: "token=${1}***"},
+		},
+	})
+
+	// Redacted files stay in place, so they have to be returned by the strategy.
+	if len(remaining) != 1 {
+		t.Errorf("expected the file to remain, got %d files", len(remaining))
+	}
+	assertContent(t, path, "user=[REDACTED] ip=[REDACTED]\nuser=[REDACTED] token=abc***\n"+long+"\nend")
+	if !strings.Contains(out, "email=2, ipv4=1, token=1") {
+		t.Errorf("expected the replacements per pattern to be reported, got %q", out)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected the modification time %v to be preserved, got %v", modTime, info.ModTime())
+	}
+	if _, err := os.Stat(tempPath(path)); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be removed, got %v", err)
+	}
+}
+
+// TestRedactInvalid tests that invalid configurations are rejected.
+func TestRedactInvalid(t *testing.T) {
+	configs := []StrategyConfig{
+		{},
+		{Redact: []string{"phone"}},
+		{Replacements: []RedactReplacement{{Pattern: "("}}},
+	}
+	for _, c := range configs {
+		c := c
+		_, err := newRedactAction(&c, &directory{}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
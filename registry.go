@@ -0,0 +1,205 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Selector is the interface a strategy implementation has to provide. It decides
+// which files of a directory are handed to the configured action.
+type Selector interface {
+	// Select returns a function that reports whether a file should be handed to the action.
+	Select(files []os.FileInfo) (func(file os.FileInfo) bool, error)
+}
+
+// StrategyContext holds everything a StrategyFactory needs to create a Selector.
+type StrategyContext struct {
+	// Config is the configuration of the strategy.
+	Config *StrategyConfig
+	// Path is the directory that is being scrubbed.
+	Path string
+	// Filesystem is the filesystem handle used by the scrubber.
+	Filesystem Filesystem
+	// Log is the logger used by the scrubber.
+	Log Logger
+
+	dir    *directory
+	action Action
+}
+
+// StrategyFactory creates the Selector for a strategy configuration.
+type StrategyFactory func(ctx StrategyContext) (Selector, error)
+
+// ActionContext holds everything an ActionFactory needs to create an Action.
+type ActionContext struct {
+	// Config is the configuration of the strategy the action belongs to.
+	Config *StrategyConfig
+	// Path is the directory that is being scrubbed.
+	Path string
+	// Filesystem is the filesystem handle used by the scrubber.
+	Filesystem Filesystem
+	// Log is the logger used by the scrubber.
+	Log Logger
+	// Pretend is true if the action should only log what it would do.
+	Pretend bool
+
+	dir *directory
+}
+
+// ActionFactory creates the Action for a strategy configuration.
+type ActionFactory func(ctx ActionContext) (Action, error)
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = make(map[StrategyType]StrategyFactory)
+
+	actionsMu sync.RWMutex
+	actions   = make(map[StrategyAction]ActionFactory)
+)
+
+// RegisterStrategy makes a strategy type available to the TOML configuration. It
+// panics if factory is nil or the type is already registered.
+func RegisterStrategy(t StrategyType, factory StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	if factory == nil {
+		panic("scrubber: RegisterStrategy factory is nil")
+	}
+	if _, ok := strategies[t]; ok {
+		panic("scrubber: RegisterStrategy called twice for type " + string(t))
+	}
+	strategies[t] = factory
+}
+
+// RegisterAction makes an action available to the TOML configuration. It panics
+// if factory is nil or the action is already registered.
+func RegisterAction(name StrategyAction, factory ActionFactory) {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+
+	if factory == nil {
+		panic("scrubber: RegisterAction factory is nil")
+	}
+	if _, ok := actions[name]; ok {
+		panic("scrubber: RegisterAction called twice for action " + string(name))
+	}
+	actions[name] = factory
+}
+
+// selectorFromConfig returns the Selector of the strategy type defined in c.
+func selectorFromConfig(c *StrategyConfig, dir *directory, fs Filesystem, action Action, log Logger) (Selector,
+	error) {
+	strategiesMu.RLock()
+	factory, ok := strategies[c.Type]
+	strategiesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy type: %s", c.Type)
+	}
+
+	return factory(StrategyContext{
+		Config:     c,
+		Path:       dir.Path,
+		Filesystem: fs,
+		Log:        log,
+		dir:        dir,
+		action:     action,
+	})
+}
+
+// selectorStrategy hands all files a Selector selects to an action.
+type selectorStrategy struct {
+	selector Selector
+	action   Action
+}
+
+// process cleans up all files that are selected by the Selector.
+func (s selectorStrategy) process(files []os.FileInfo) ([]os.FileInfo, error) {
+	check, err := s.selector.Select(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.action.Perform(files, check)
+}
+
+// init registers all built-in strategies and actions.
+func init() {
+	RegisterAction(ActionTypeDelete, func(ctx ActionContext) (Action, error) {
+		return newDeleteAction(ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend), nil
+	})
+	RegisterAction(ActionTypeShred, func(ctx ActionContext) (Action, error) {
+		return newShredAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeRedact, func(ctx ActionContext) (Action, error) {
+		return newRedactAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeZip, func(ctx ActionContext) (Action, error) {
+		return newZipAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeGzip, func(ctx ActionContext) (Action, error) {
+		return newGzipAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeZstd, func(ctx ActionContext) (Action, error) {
+		return newZstdAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeXz, func(ctx ActionContext) (Action, error) {
+		return newXzAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeBundle, func(ctx ActionContext) (Action, error) {
+		return newBundleAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeMove, func(ctx ActionContext) (Action, error) {
+		return newMoveAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeUpload, func(ctx ActionContext) (Action, error) {
+		return newUploadAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeRotate, func(ctx ActionContext) (Action, error) {
+		return newRotateAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeTruncate, func(ctx ActionContext) (Action, error) {
+		return newTruncateAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+	RegisterAction(ActionTypeTrash, func(ctx ActionContext) (Action, error) {
+		return newTrashAction(ctx.Config, ctx.dir, ctx.Filesystem, ctx.Log, ctx.Pretend)
+	})
+
+	RegisterStrategy(StrategyTypeAge, func(ctx StrategyContext) (Selector, error) {
+		return newAgeStrategy(ctx.Config, ctx.dir, ctx.Filesystem, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeSize, func(ctx StrategyContext) (Selector, error) {
+		return newSizeStrategy(ctx.Config, ctx.dir, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeCount, func(ctx StrategyContext) (Selector, error) {
+		return newCountStrategy(ctx.Config, ctx.dir, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeQuota, func(ctx StrategyContext) (Selector, error) {
+		return newQuotaStrategy(ctx.Config, ctx.dir, ctx.Filesystem, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeFree, func(ctx StrategyContext) (Selector, error) {
+		return newFreeStrategy(ctx.Config, ctx.dir, ctx.Filesystem, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeGFS, func(ctx StrategyContext) (Selector, error) {
+		return newGFSStrategy(ctx.Config, ctx.dir, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeCompound, func(ctx StrategyContext) (Selector, error) {
+		s, err := newCompoundStrategy(ctx.Config, ctx.dir, ctx.Filesystem, ctx.action, ctx.Log)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+	RegisterStrategy(StrategyTypeRotate, func(ctx StrategyContext) (Selector, error) {
+		return newRotateStrategy(ctx.Config, ctx.dir, ctx.action, ctx.Log), nil
+	})
+	RegisterStrategy(StrategyTypeExpr, func(ctx StrategyContext) (Selector, error) {
+		s, err := newExprStrategy(ctx.Config, ctx.dir, ctx.Filesystem, ctx.action, ctx.Log)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
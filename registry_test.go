@@ -0,0 +1,155 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// prefixSelector is a custom strategy that selects all files with a certain prefix.
+type prefixSelector struct {
+	prefix string
+}
+
+// Select selects all files that start with the prefix.
+func (s prefixSelector) Select(files []os.FileInfo) (func(file os.FileInfo) bool, error) {
+	return func(file os.FileInfo) bool {
+		return strings.HasPrefix(file.Name(), s.prefix)
+	}, nil
+}
+
+// unregisterStrategy removes a strategy type registered by a test.
+func unregisterStrategy(t StrategyType) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	delete(strategies, t)
+}
+
+// TestRegisterStrategy tests that registered strategy types can be used from the configuration.
+func TestRegisterStrategy(t *testing.T) {
+	RegisterStrategy("prefix", func(ctx StrategyContext) (Selector, error) {
+		return prefixSelector{ctx.Config.Limit}, nil
+	})
+	t.Cleanup(func() { unregisterStrategy("prefix") })
+
+	dir := t.TempDir()
+	fs := &mockedFs{files: []os.FileInfo{
+		mockedFileInfo{name: "tmp-upload.bin"},
+		mockedFileInfo{name: "upload.bin"},
+	}}
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Strategies: []StrategyConfig{{Type: "prefix", Limit: "tmp-", Action: ActionTypeDelete}},
+	}}}
+
+	err := New(c, fs, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(fs.deleted) != 1 || fs.deleted[0] != dir+"/tmp-upload.bin" {
+		t.Errorf("expected only \"tmp-upload.bin\" to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestRegisterStrategyTwice tests that a strategy type cannot be registered twice.
+func TestRegisterStrategyTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected RegisterStrategy to panic for a built-in type")
+		}
+	}()
+
+	RegisterStrategy(StrategyTypeAge, func(ctx StrategyContext) (Selector, error) {
+		return nil, nil
+	})
+}
+
+// TestUnknownStrategy tests that unknown strategy types are being rejected.
+func TestUnknownStrategy(t *testing.T) {
+	c := &TomlConfig{Directories: []directory{{
+		Path:       t.TempDir(),
+		Strategies: []StrategyConfig{{Type: "unknown", Action: ActionTypeDelete}},
+	}}}
+
+	err := New(c, &mockedFs{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err == nil {
+		t.Errorf("expected an error for an unknown strategy type")
+	}
+}
+
+// recordingAction is a custom action that records all files it is handed.
+type recordingAction struct {
+	path    string
+	handled *[]string
+}
+
+// Perform records all files check returns true for.
+func (a recordingAction) Perform(files []os.FileInfo, check func(file os.FileInfo) bool) ([]os.FileInfo, error) {
+	var remaining []os.FileInfo
+	for _, file := range files {
+		if check(file) {
+			*a.handled = append(*a.handled, a.path+"/"+file.Name())
+			continue
+		}
+		remaining = append(remaining, file)
+	}
+	return remaining, nil
+}
+
+// unregisterAction removes an action registered by a test.
+func unregisterAction(name StrategyAction) {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+
+	delete(actions, name)
+}
+
+// TestRegisterAction tests that registered actions can be used from the configuration.
+func TestRegisterAction(t *testing.T) {
+	var handled []string
+	RegisterAction("record", func(ctx ActionContext) (Action, error) {
+		return recordingAction{ctx.Path, &handled}, nil
+	})
+	t.Cleanup(func() { unregisterAction("record") })
+
+	dir := t.TempDir()
+	fs := &mockedFs{files: []os.FileInfo{
+		mockedFileInfo{name: "big.log", size: 20},
+		mockedFileInfo{name: "small.log", size: 5},
+	}}
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Strategies: []StrategyConfig{{Type: StrategyTypeSize, Limit: "10b", Action: "record"}},
+	}}}
+
+	err := New(c, fs, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err != nil {
+		t.Errorf("expected no error, got %v\n", err)
+	}
+
+	if len(handled) != 1 || handled[0] != dir+"/big.log" {
+		t.Errorf("expected only \"big.log\" to be handled got %v.\n", handled)
+	}
+	if len(fs.deleted) != 0 {
+		t.Errorf("expected no files to be removed got %v.\n", fs.deleted)
+	}
+}
+
+// TestUnknownAction tests that unknown actions are reported as configuration errors.
+func TestUnknownAction(t *testing.T) {
+	c := &TomlConfig{Directories: []directory{{
+		Path:       t.TempDir(),
+		Strategies: []StrategyConfig{{Type: StrategyTypeAge, Limit: "1d", Action: "unknown"}},
+	}}}
+
+	err := New(c, &mockedFs{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err == nil || !strings.Contains(err.Error(), "unknown action type") {
+		t.Errorf("expected an unknown action error, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// RotateMode defines how a live log file is rotated.
+type RotateMode string
+
+const (
+	// RotateModeCreate renames the live file and creates a new, empty one.
+	RotateModeCreate RotateMode = "create"
+	// RotateModeCopyTruncate copies the live file and truncates it afterwards.
+	RotateModeCopyTruncate RotateMode = "copytruncate"
+)
+
+// generationPattern matches the names of rotated log files like app.log.1 or app.log.2.gz
+// and the path files of compressed generations like app.log.2.gz.path.
+var generationPattern = regexp.MustCompile(`\.\d+(\.gz(\.path)?)?$`)
+
+// generationExts are the extensions of all files that belong to a generation.
+var generationExts = []string{"", ".gz", ".gz" + pathFileExt}
+
+// rotateStrategy represents the action of selecting live log files that should be rotated.
+type rotateStrategy struct {
+	Strategy
+	limit int64
+}
+
+// newRotateStrategy returns a new rotateStrategy.
+func newRotateStrategy(c *StrategyConfig, dir *directory, action Action, log Logger) *rotateStrategy {
+	return &rotateStrategy{Strategy{c, dir, action, log}, 0}
+}
+
+// Select selects all live log files that are not empty. Rotated generations are
+// never selected. If a limit is set, only files greater than the limit are selected.
+func (s rotateStrategy) Select(files []os.FileInfo) (checkFn, error) {
+	if s.c.Limit != "" {
+		limit, err := parseSize([]byte(s.c.Limit))
+		if err != nil {
+			return nil, err
+		}
+		s.limit = limit
+	}
+
+	return func(file os.FileInfo) bool {
+		return !generationPattern.MatchString(file.Name()) && file.Size() > 0 && file.Size() > s.limit
+	}, nil
+}
+
+// rotateAction represents the action of rotating log files.
+type rotateAction struct {
+	action
+	mode        RotateMode
+	generations int
+	compress    *gzipAction
+}
+
+// newRotateAction returns a pointer to a rotateAction.
+func newRotateAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*rotateAction, error) {
+	mode := c.Mode
+	switch mode {
+	case "":
+		mode = RotateModeCreate
+	case RotateModeCreate, RotateModeCopyTruncate:
+	default:
+		return nil, fmt.Errorf("unknown rotate mode %q, expected create or copytruncate", c.Mode)
+	}
+
+	if c.Generations < 1 {
+		return nil, fmt.Errorf("rotate action requires at least one generation")
+	}
+	if c.Encrypt {
+		return nil, fmt.Errorf("rotate action does not support encrypt")
+	}
+
+	a := &rotateAction{action: action{dir, fs, log, pretend}, mode: mode, generations: c.Generations}
+	if c.Compress {
+		var err error
+		a.compress, err = newGzipAction(c, dir, fs, log, pretend)
+		if err != nil {
+			return nil, err
+		}
+		// A numbered archive like app.log.2.1.gz would never be shifted or removed,
+		// so generations whose archive already exists are left uncompressed.
+		a.compress.opts.collision = CollisionSkip
+	}
+
+	return a, nil
+}
+
+// Perform rotates all selected log files.
+func (a rotateAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Rotate] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		if a.pretend {
+			a.log.Printf("[Rotate] PRETEND: Would rotate file %s (%s)", filename, a.mode)
+			continue
+		}
+
+		a.log.Printf("[Rotate] Rotating file %s (%s)", filename, a.mode)
+
+		err := a.rotate(filename, file)
+		if err != nil {
+			return files, fmt.Errorf("failed to rotate file %s: %s", filename, err)
+		}
+	}
+	return newFiles, nil
+}
+
+// rotate shifts all generations of filename and rotates the live file to generation 1.
+func (a rotateAction) rotate(filename string, file os.FileInfo) error {
+	err := a.removeGeneration(filename, a.generations)
+	if err != nil {
+		return err
+	}
+
+	for i := a.generations - 1; i >= 1; i-- {
+		for _, ext := range generationExts {
+			from := fmt.Sprintf("%s.%d%s", filename, i, ext)
+			to := fmt.Sprintf("%s.%d%s", filename, i+1, ext)
+			err = a.fs.Rename(from, to)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	// Generation 1 is never compressed, so the previous generation 1 is compressed
+	// after it has been shifted to generation 2 (delaycompress). The generations
+	// have already been shifted, so a failed compression leaves it uncompressed.
+	if a.compress != nil && a.generations > 1 {
+		previous := fmt.Sprintf("%s.2", filename)
+		if _, err := a.fs.Stat(previous); err == nil {
+			err = a.compress.gzip(previous)
+			if err == nil {
+				err = a.fs.Remove(previous)
+			}
+			if errors.Is(err, errArchiveSkipped) {
+				a.log.Printf("[Rotate] Leaving %s uncompressed, its archive already exists", previous)
+			} else if err != nil {
+				a.log.Printf("[Rotate] ERROR: Failed to compress %s, leaving it uncompressed: %s", previous, err)
+			}
+		}
+	}
+
+	first := filename + ".1"
+	if a.mode == RotateModeCopyTruncate {
+		return a.copyTruncate(filename, first)
+	}
+
+	err = a.fs.Rename(filename, first)
+	if err != nil {
+		return err
+	}
+
+	live, err := a.fs.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, file.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create new log file: %v", err)
+	}
+	return live.Close()
+}
+
+// removeGeneration removes generation n of filename. Generation n is the one
+// that would be shifted past the number of generations to keep.
+func (a rotateAction) removeGeneration(filename string, n int) error {
+	for _, ext := range generationExts {
+		err := a.fs.Remove(fmt.Sprintf("%s.%d%s", filename, n, ext))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTruncate copies the live file to dst and truncates it afterwards, so
+// processes that never reopen their log file can keep writing to it.
+func (a rotateAction) copyTruncate(filename, dst string) error {
+	info, err := a.fs.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	live, err := a.fs.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer live.Close()
+
+	out, err := a.fs.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, live)
+	if err == nil {
+		err = out.Sync()
+	}
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy log file: %v", err)
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+
+	err = a.fs.Chtimes(dst, info.ModTime(), info.ModTime())
+	if err != nil {
+		return err
+	}
+
+	return live.Truncate(0)
+}
@@ -0,0 +1,192 @@
+package scrubber
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRotateCreate tests that the live file is renamed and recreated.
+func TestRotateCreate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "second", time.Now())
+	writeTestFile(t, path+".1", "first", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 3})
+
+	assertContent(t, path, "")
+	assertContent(t, path+".1", "second")
+	assertContent(t, path+".2", "first")
+}
+
+// TestRotateCopyTruncate tests that the live file keeps its inode and is truncated.
+func TestRotateCopyTruncate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "content", time.Now())
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 2, Mode: RotateModeCopyTruncate})
+
+	assertContent(t, path+".1", "content")
+	_, err = f.WriteString("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, path, "new")
+}
+
+// TestRotateGenerations tests that no more than the configured number of generations are kept.
+func TestRotateGenerations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "live", time.Now())
+	writeTestFile(t, path+".1", "one", time.Now())
+	writeTestFile(t, path+".2", "two", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 2})
+
+	assertContent(t, path+".1", "live")
+	assertContent(t, path+".2", "one")
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected %s.3 to not exist, got %v", path, err)
+	}
+}
+
+// TestRotateCompress tests that all generations except the first one are compressed.
+func TestRotateCompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "live", time.Now())
+	writeTestFile(t, path+".1", "one", time.Now())
+	writeTestFile(t, path+".2.gz", "", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 3, Compress: true})
+
+	assertContent(t, path+".1", "live")
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("expected %s.2 to be compressed, got %v", path, err)
+	}
+	if _, err := os.Stat(path + ".3.gz"); err != nil {
+		t.Errorf("expected %s.3.gz to exist, got %v", path, err)
+	}
+
+	f, err := os.Open(path + ".2.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "one" {
+		t.Errorf("expected %s.2.gz to contain %q, got %q", path, "one", content)
+	}
+}
+
+// TestRotatePathFile tests that the path files of compressed generations are shifted and removed with them.
+func TestRotatePathFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "live", time.Now())
+	writeTestFile(t, path+".2.gz", "", time.Now())
+	writeTestFile(t, path+".2.gz"+pathFileExt, "two", time.Now())
+	writeTestFile(t, path+".3.gz"+pathFileExt, "three", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 3, Compress: true})
+
+	assertContent(t, path+".3.gz"+pathFileExt, "two")
+	if names := dirNames(t, dir); len(names) != 4 {
+		t.Errorf("expected app.log, app.log.1 and generation 3 to be left, got %v", names)
+	}
+}
+
+// TestRotateSelect tests that only live files greater than the limit are selected.
+func TestRotateSelect(t *testing.T) {
+	s := newRotateStrategy(&StrategyConfig{Limit: "2b"}, &directory{}, nil, nil)
+	check, err := s.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     mockedFileInfo
+		expected bool
+	}{
+		{mockedFileInfo{name: "app.log", size: 3}, true},
+		{mockedFileInfo{name: "app.log", size: 2}, false},
+		{mockedFileInfo{name: "app.log.1", size: 3}, false},
+		{mockedFileInfo{name: "app.log.2.gz", size: 3}, false},
+		{mockedFileInfo{name: "app.log.2.gz.path", size: 3}, false},
+	}
+	for _, test := range tests {
+		if actual := check(test.file); actual != test.expected {
+			t.Errorf("expected %v for %s with size %d, got %v", test.expected, test.file.name, test.file.size, actual)
+		}
+	}
+}
+
+// TestRotateInvalid tests that invalid configurations are rejected.
+func TestRotateInvalid(t *testing.T) {
+	_, recipient := writeIdentity(t)
+	configs := []StrategyConfig{
+		{},
+		{Generations: 2, Mode: "move"},
+		{Generations: 2, Encrypt: true, Recipients: []string{recipient}},
+	}
+	for _, c := range configs {
+		c := c
+		_, err := newRotateAction(&c, &directory{}, OSFilesystem{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+// TestRotateCompressSkipped tests that a generation whose archive already exists is left uncompressed.
+func TestRotateCompressSkipped(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "live", time.Now())
+	writeTestFile(t, path+".1", "one", time.Now())
+	writeTestFile(t, path+".1.gz", "", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 3, Compress: true})
+
+	assertContent(t, path, "")
+	assertContent(t, path+".1", "live")
+	assertContent(t, path+".2", "one")
+	if names := dirNames(t, dir); len(names) != 4 {
+		t.Errorf("expected no numbered archive to be created, got %v", names)
+	}
+}
+
+// TestRotateKeepLatest tests that the rotate strategy cannot be combined with keep_latest.
+func TestRotateKeepLatest(t *testing.T) {
+	c := &TomlConfig{Directories: []directory{{
+		Path:       t.TempDir(),
+		KeepLatest: 1,
+		Strategies: []StrategyConfig{{Type: StrategyTypeRotate, Action: ActionTypeRotate, Generations: 2}},
+	}}}
+
+	err := New(c, &mockedFs{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err == nil {
+		t.Errorf("expected an error for keep_latest with a rotate strategy")
+	}
+}
@@ -0,0 +1,514 @@
+package scrubber
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultS3Region is used if neither the config nor AWS_REGION sets a region.
+const defaultS3Region = "us-east-1"
+
+// defaultS3Timeout is how long a single request may take if no timeout is configured.
+const defaultS3Timeout = 10 * time.Minute
+
+// errObjectExists is returned by conditional requests if the object already exists.
+var errObjectExists = errors.New("object already exists")
+
+// S3Config holds the bucket an upload action writes to.
+type S3Config struct {
+	// Endpoint is the URL of the S3-compatible service, defaults to AWS in the configured region.
+	Endpoint string
+	// Region is the region requests are signed for, defaults to AWS_REGION or us-east-1.
+	Region string
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Key is a template for the object key, like {{.DirName}}/{{.Name}}.
+	Key string
+	// PathStyle puts the bucket into the path instead of the host name, which most self-hosted services require.
+	PathStyle bool `toml:"path_style"`
+	// Credentials is the path of a shared credentials file, which takes precedence over the environment.
+	Credentials string
+	// Profile is the profile of the credentials file, defaults to AWS_PROFILE or default.
+	Profile string
+	// PartSize is the size of the parts of multipart uploads, files up to this size are uploaded at once.
+	PartSize string `toml:"part_size"`
+	// Timeout is how long a single request, like the upload of a part, may take.
+	Timeout string
+}
+
+// s3Credentials are the keys requests are signed with.
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// loadS3Credentials returns the credentials of a configured credentials file, the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables or the shared
+// credentials file in its default location, in this order.
+func loadS3Credentials(fs Filesystem, c S3Config) (s3Credentials, error) {
+	profile := c.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	if c.Credentials != "" {
+		return readS3Credentials(fs, c.Credentials, profile)
+	}
+
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		creds := s3Credentials{os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}
+		if creds.secretKey == "" {
+			return s3Credentials{}, fmt.Errorf("AWS_ACCESS_KEY_ID is set without AWS_SECRET_ACCESS_KEY")
+		}
+		return creds, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return s3Credentials{}, fmt.Errorf("no S3 credentials found")
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	if _, err := fs.Stat(path); err != nil {
+		return s3Credentials{}, fmt.Errorf("no S3 credentials found in the environment or %s", path)
+	}
+	return readS3Credentials(fs, path, profile)
+}
+
+// readS3Credentials reads a profile from a shared credentials file in INI format.
+func readS3Credentials(fs Filesystem, path, profile string) (s3Credentials, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return s3Credentials{}, fmt.Errorf("failed to open credentials file: %v", err)
+	}
+	defer f.Close()
+
+	var creds s3Credentials
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "aws_access_key_id":
+			creds.accessKey = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.secretKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.sessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return s3Credentials{}, fmt.Errorf("failed to read credentials file: %v", err)
+	}
+
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return s3Credentials{}, fmt.Errorf("no credentials for profile %s in %s", profile, path)
+	}
+	return creds, nil
+}
+
+// s3Client sends signed requests to a single bucket of an S3-compatible service.
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	pathStyle bool
+	creds     s3Credentials
+	http      *http.Client
+	now       func() time.Time
+}
+
+// newS3Client returns a client for the bucket of c.
+func newS3Client(c S3Config, creds s3Credentials) (*s3Client, error) {
+	if c.Bucket == "" {
+		return nil, fmt.Errorf("s3 requires a bucket")
+	}
+
+	region := c.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+
+	timeout := defaultS3Timeout
+	if c.Timeout != "" {
+		timeout, err = parseAge(c.Timeout)
+		if err != nil || timeout == 0 {
+			return nil, fmt.Errorf("invalid s3 timeout %q", c.Timeout)
+		}
+	}
+
+	return &s3Client{u, region, c.Bucket, c.PathStyle, creds, &http.Client{Timeout: timeout}, time.Now}, nil
+}
+
+// objectURL returns the URL of an object.
+func (c *s3Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if c.pathStyle {
+		path += "/" + c.bucket
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	u.Path = path + "/" + key
+	u.RawPath = s3Escape(path, false) + "/" + s3Escape(key, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// s3Error is the error document returned by S3.
+type s3Error struct {
+	Code    string
+	Message string
+
+	// request, status and statusCode describe the failed request, they are set by do.
+	request    string
+	status     string
+	statusCode int
+}
+
+// Error returns the code and message of the error document, or the status if the response had none.
+func (e *s3Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s failed: %s: %s", e.request, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s failed: %s", e.request, e.status)
+}
+
+// exists reports whether a conditional request failed because the object already exists.
+func (e *s3Error) exists() bool {
+	return e.statusCode == http.StatusPreconditionFailed || e.Code == "ConditionalRequestConflict"
+}
+
+// do sends a signed request. body must contain exactly size bytes whose SHA-256
+// is payloadHash. Responses outside of 2xx are returned as error.
+func (c *s3Client) do(method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequest(method, c.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, payloadHash)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		e := &s3Error{request: method + " " + key, status: resp.Status, statusCode: resp.StatusCode}
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(content, e) != nil {
+			e.Code = ""
+		}
+		if e.exists() {
+			return nil, errObjectExists
+		}
+		return nil, e
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 to req. All headers set so far are signed.
+func (c *s3Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.creds.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.creds.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA-256 of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3Escape encodes s as required by Signature Version 4. Slashes are only encoded if encodeSlash is true.
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (ch == '/' && !encodeSlash) {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// canonicalQuery encodes query sorted by key as required by Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// partHash holds the hashes of a part of a file that are sent along with it.
+type partHash struct {
+	md5    []byte
+	sha256 string
+}
+
+// hashPart reads r completely and returns its MD5 and SHA-256.
+func hashPart(r io.Reader) (partHash, error) {
+	m := md5.New()
+	s := sha256.New()
+	_, err := io.Copy(io.MultiWriter(m, s), r)
+	if err != nil {
+		return partHash{}, err
+	}
+	return partHash{m.Sum(nil), hex.EncodeToString(s.Sum(nil))}, nil
+}
+
+// contentMD5 returns the value of the Content-MD5 header.
+func (h partHash) contentMD5() string {
+	return base64.StdEncoding.EncodeToString(h.md5)
+}
+
+// etag returns the ETag S3 returns for a single part or object uploaded at once.
+func (h partHash) etag() string {
+	return `"` + hex.EncodeToString(h.md5) + `"`
+}
+
+// putObject uploads r with a single request and returns the ETag of the object.
+func (c *s3Client) putObject(key string, r io.Reader, size int64, hash partHash, noReplace bool) (string, error) {
+	header := http.Header{"Content-Md5": {hash.contentMD5()}}
+	if noReplace {
+		header.Set("If-None-Match", "*")
+	}
+	resp, err := c.do(http.MethodPut, key, nil, header, r, size, hash.sha256)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// createMultipartUpload starts a multipart upload and returns its ID.
+func (c *s3Client) createMultipartUpload(key string) (string, error) {
+	resp, err := c.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("invalid response to multipart upload of %s: %v", key, err)
+	}
+	return result.UploadID, nil
+}
+
+// uploadPart uploads a single part of a multipart upload and returns its ETag.
+func (c *s3Client) uploadPart(key, uploadID string, number int, r io.Reader, size int64, hash partHash) (string, error) {
+	query := url.Values{"partNumber": {fmt.Sprint(number)}, "uploadId": {uploadID}}
+	header := http.Header{"Content-Md5": {hash.contentMD5()}}
+	resp, err := c.do(http.MethodPut, key, query, header, r, size, hash.sha256)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// completedPart is a part of a multipart upload that is being completed.
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// completeMultipartUpload assembles the parts of a multipart upload and returns the ETag of the object.
+func (c *s3Client) completeMultipartUpload(key, uploadID string, parts []completedPart, noReplace bool) (string, error) {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return "", err
+	}
+
+	var header http.Header
+	if noReplace {
+		header = http.Header{"If-None-Match": {"*"}}
+	}
+	resp, err := c.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, header, strings.NewReader(string(body)), int64(len(body)), sha256Hex(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Errors can also be reported after the request has been accepted.
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		XMLName xml.Name
+		ETag    string
+		Code    string
+		Message string
+	}
+	err = xml.Unmarshal(content, &result)
+	if err != nil {
+		return "", fmt.Errorf("invalid response to completing the upload of %s: %v", key, err)
+	}
+	if result.XMLName.Local == "Error" && result.Code == "PreconditionFailed" {
+		return "", errObjectExists
+	}
+	if result.XMLName.Local == "Error" {
+		return "", fmt.Errorf("completing the upload of %s failed: %s: %s", key, result.Code, result.Message)
+	}
+	return result.ETag, nil
+}
+
+// headObject reports whether an object exists.
+func (c *s3Client) headObject(key string) (bool, error) {
+	resp, err := c.do(http.MethodHead, key, nil, nil, nil, 0, sha256Hex(nil))
+	var e *s3Error
+	if errors.As(err, &e) && e.statusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// deleteObject removes an object.
+func (c *s3Client) deleteObject(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, nil, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// abortMultipartUpload removes all uploaded parts of a multipart upload.
+func (c *s3Client) abortMultipartUpload(key, uploadID string) error {
+	resp, err := c.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, 0, sha256Hex(nil))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
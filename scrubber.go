@@ -2,17 +2,17 @@
 package scrubber
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"slices"
 )
 
 // Scrubber holds the configuration and a filesystem handle.
 type Scrubber struct {
 	config  *TomlConfig
 	fs      Filesystem
-	log     logger
+	log     Logger
 	pretend bool
 }
 
@@ -26,8 +26,8 @@ type TomlConfig struct {
 type Strategy struct {
 	c      *StrategyConfig
 	dir    *directory
-	action performer
-	log    logger
+	action Action
+	log    Logger
 }
 
 // StrategyConfig holds all specified strategies for a single Directory.
@@ -35,8 +35,60 @@ type StrategyConfig struct {
 	Type   StrategyType
 	Action StrategyAction
 	Limit  string
+	Target string
+
+	KeepDaily   int `toml:"keep_daily"`
+	KeepWeekly  int `toml:"keep_weekly"`
+	KeepMonthly int `toml:"keep_monthly"`
+	KeepYearly  int `toml:"keep_yearly"`
+
+	NameTimePattern string   `toml:"name_time_pattern"`
+	NameTimeLayout  string   `toml:"name_time_layout"`
+	AgeBasis        AgeBasis `toml:"age_basis"`
+
+	Level       int
+	Threads     int
+	Archive     string
+	Bucket      string
+	Destination string
+	Collision   CollisionPolicy
+	Mode        RotateMode
+	Generations int
+	Compress    bool
+	Keep        string
+	KeepLines   int `toml:"keep_lines"`
+	Trash       string
+	Grace       string
+	Passes      int
+	Fill        ShredFill
+	Rename      bool
+	Redact      []string
+
+	Replacements []RedactReplacement `toml:"replacement"`
+
+	PreserveMode  bool `toml:"preserve_mode"`
+	PreserveOwner bool `toml:"preserve_owner"`
+
+	Encrypt    bool
+	Recipients []string
+
+	S3 S3Config
+
+	Match      StrategyMatch
+	Not        bool
+	Conditions []StrategyConfig `toml:"condition"`
 }
 
+// StrategyMatch defines how the conditions of a compound strategy are combined.
+type StrategyMatch string
+
+const (
+	// MatchAll selects files that match all conditions.
+	MatchAll StrategyMatch = "all"
+	// MatchAny selects files that match at least one condition.
+	MatchAny StrategyMatch = "any"
+)
+
 // StrategyType defines how to decide what files should be cleaned up.
 type StrategyType string
 
@@ -45,6 +97,20 @@ const (
 	StrategyTypeAge StrategyType = "age"
 	// StrategyTypeSize makes files past a certain size to be deleted.
 	StrategyTypeSize StrategyType = "size"
+	// StrategyTypeCount makes all files but the newest n to be deleted.
+	StrategyTypeCount StrategyType = "count"
+	// StrategyTypeQuota makes the oldest files to be deleted until a directory fits into a size budget.
+	StrategyTypeQuota StrategyType = "quota"
+	// StrategyTypeFree makes the oldest files to be deleted when the free disk space drops below a threshold.
+	StrategyTypeFree StrategyType = "free"
+	// StrategyTypeGFS keeps the newest file of the last days, weeks, months and years and deletes the rest.
+	StrategyTypeGFS StrategyType = "gfs"
+	// StrategyTypeCompound makes files to be deleted that match a combination of nested conditions.
+	StrategyTypeCompound StrategyType = "compound"
+	// StrategyTypeExpr makes files to be deleted that match a filter expression.
+	StrategyTypeExpr StrategyType = "expr"
+	// StrategyTypeRotate selects live log files that should be rotated.
+	StrategyTypeRotate StrategyType = "rotate"
 )
 
 // StrategyAction represents the action that should be taken for matching files.
@@ -53,23 +119,44 @@ type StrategyAction string
 const (
 	// ActionTypeDelete is used to delete old files.
 	ActionTypeDelete StrategyAction = "delete"
+	// ActionTypeShred is used to overwrite old files before deleting them.
+	ActionTypeShred StrategyAction = "shred"
+	// ActionTypeRedact is used to replace sensitive data in files.
+	ActionTypeRedact StrategyAction = "redact"
 	// ActionTypeZip is used to zip old files.
 	ActionTypeZip StrategyAction = "zip"
+	// ActionTypeGzip is used to gzip old files.
+	ActionTypeGzip StrategyAction = "gzip"
+	// ActionTypeZstd is used to compress old files using zstd.
+	ActionTypeZstd StrategyAction = "zstd"
+	// ActionTypeXz is used to compress old files using xz.
+	ActionTypeXz StrategyAction = "xz"
+	// ActionTypeBundle is used to collect old files in a single archive.
+	ActionTypeBundle StrategyAction = "bundle"
+	// ActionTypeMove is used to move old files to another location.
+	ActionTypeMove StrategyAction = "move"
+	// ActionTypeUpload is used to upload old files to an S3-compatible bucket.
+	ActionTypeUpload StrategyAction = "upload"
+	// ActionTypeRotate is used to rotate log files.
+	ActionTypeRotate StrategyAction = "rotate"
+	// ActionTypeTruncate is used to shrink files in place to their tail.
+	ActionTypeTruncate StrategyAction = "truncate"
+	// ActionTypeTrash is used to move old files to a trash directory they can be restored from.
+	ActionTypeTrash StrategyAction = "trash"
 )
 
-// processor is the interface that wraps the single method a strategy implementation has to provide.
+// processor is the interface that wraps a configured strategy, which hands the files it selects to its action.
 type processor interface {
 	process(files []os.FileInfo) ([]os.FileInfo, error)
 }
 
-// logger defines the the minimal logging functionality we expect.
-type logger interface {
+// Logger defines the minimal logging functionality we expect.
+type Logger interface {
 	Printf(string, ...interface{})
-	Fatalf(format string, v ...interface{})
 }
 
 // New returns a new instance of Scrubber.
-func New(c *TomlConfig, fs Filesystem, log logger, pretend bool) *Scrubber {
+func New(c *TomlConfig, fs Filesystem, log Logger, pretend bool) *Scrubber {
 	return &Scrubber{
 		config:  c,
 		fs:      fs,
@@ -78,8 +165,19 @@ func New(c *TomlConfig, fs Filesystem, log logger, pretend bool) *Scrubber {
 	}
 }
 
-// Scrub performs the actual cleanup.
+// Scrub performs the actual cleanup. Failing hooks don't stop the cleanup
+// of other directories, their errors are returned once all directories are done.
 func (s Scrubber) Scrub() error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+
+	runID := newRunID()
+	s.log.Printf("Starting run %s", runID)
+
+	var hookErrs []error
+
 	for _, configDir := range s.config.Directories {
 
 		expandedDirs, err := s.expandDirs(configDir.Path)
@@ -95,6 +193,10 @@ func (s Scrubber) Scrub() error {
 
 		for _, expandedDir := range expandedDirs {
 			dir := configDir.WithPath(expandedDir)
+			dir.runID = runID
+
+			s.cleanTempFiles(&dir)
+			s.purgeTrash(&dir)
 
 			s.log.Printf("Scanning for files in %s...", dir.Path)
 
@@ -105,15 +207,11 @@ func (s Scrubber) Scrub() error {
 				continue
 			}
 
-			// Sort files by modification time.
-			slices.SortFunc(files, func(i, j os.FileInfo) int {
-				if i.ModTime().After(j.ModTime()) {
-					return 0
-				}
-				return 1
-			})
+			files, err = s.sortFiles(&dir, scanner.filterFiles(files))
+			if err != nil {
+				return err
+			}
 
-			files = scanner.filterFiles(files)
 			files = ApplyKeepLatest(files, dir.KeepLatest)
 
 			if len(files) < 1 {
@@ -123,16 +221,54 @@ func (s Scrubber) Scrub() error {
 
 			s.log.Printf("Found %d files to process", len(files))
 
+			var affected []string
+			seen := make(map[string]bool)
 			for _, strategy := range dir.Strategies {
 				strategy := strategy
-				s, err := strategyFromConfig(&strategy, &dir, s.fs, s.log, s.pretend)
+				p, err := strategyFromConfig(&strategy, &dir, s.fs, s.log, s.pretend)
 				if err != nil {
 					return err
 				}
-				_, err = s.process(files)
+				remaining, err := p.process(files)
 				if err != nil {
 					return fmt.Errorf("error while processing files: %s", err)
 				}
+				for _, path := range affectedFiles(s.fs, &dir, files, remaining) {
+					if !seen[path] {
+						seen[path] = true
+						affected = append(affected, path)
+					}
+				}
+			}
+
+			err = s.runHooks(&dir, affected)
+			if err != nil {
+				hookErrs = append(hookErrs, err)
+			}
+		}
+	}
+
+	return errors.Join(hookErrs...)
+}
+
+// validate makes sure all configured strategies and actions can be created before any file is touched.
+func (s Scrubber) validate() error {
+	for _, dir := range s.config.Directories {
+		dir := dir
+		for _, c := range dir.Strategies {
+			c := c
+			if c.Type == StrategyTypeRotate && dir.KeepLatest > 0 {
+				return fmt.Errorf("invalid %s strategy for directory %s: keep_latest would exempt the live log file", c.Type, dir.Path)
+			}
+			_, err := strategyFromConfig(&c, &dir, s.fs, s.log, s.pretend)
+			if err != nil {
+				return fmt.Errorf("invalid %s strategy for directory %s: %s", c.Type, dir.Path, err)
+			}
+		}
+		for _, hook := range dir.Hooks {
+			err := hook.validate()
+			if err != nil {
+				return fmt.Errorf("invalid hook for directory %s: %s", dir.Path, err)
 			}
 		}
 	}
@@ -140,6 +276,24 @@ func (s Scrubber) Scrub() error {
 	return nil
 }
 
+// sortFiles sorts files newest first. If the directory defines a name_time_pattern,
+// files are sorted by the time in their name and files without one are skipped.
+func (s Scrubber) sortFiles(dir *directory, files []os.FileInfo) ([]os.FileInfo, error) {
+	fileTime, err := newFileTimeFn(dir.NameTimePattern, dir.NameTimeLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	dated, _ := sortByTime(files, fileTime, s.log)
+
+	sorted := make([]os.FileInfo, len(dated))
+	for i, file := range dated {
+		sorted[i] = file.FileInfo
+	}
+
+	return sorted, nil
+}
+
 // expandDirs expands a Glob pattern and returns all directories.
 func (s Scrubber) expandDirs(path string) ([]string, error) {
 	expandedPaths, err := filepath.Glob(path)
@@ -163,14 +317,18 @@ func (s Scrubber) expandDirs(path string) ([]string, error) {
 }
 
 // strategyFromConfig returns the strategy defined in the configuration file.
-func strategyFromConfig(c *StrategyConfig, dir *directory, fs Filesystem, log logger, pretend bool) (processor,
+func strategyFromConfig(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (processor,
 	error) {
-	action := actionFromConfig(c, dir, fs, log, pretend)
-	switch c.Type {
-	case StrategyTypeAge:
-		return newAgeStrategy(c, dir, action, log), nil
-	case StrategyTypeSize:
-		return newSizeStrategy(c, dir, action, log), nil
+	if c.Not {
+		return nil, fmt.Errorf("not is only supported by conditions of compound strategies")
+	}
+	action, err := actionFromConfig(c, dir, fs, log, pretend)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := selectorFromConfig(c, dir, fs, action, log)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("unknown strategy type: %s", c.Type)
+	return &selectorStrategy{selector, action}, nil
 }
@@ -0,0 +1,169 @@
+package scrubber
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ShredFill defines what a file is overwritten with before it is removed.
+type ShredFill string
+
+const (
+	// ShredFillRandom overwrites files with random data.
+	ShredFillRandom ShredFill = "random"
+	// ShredFillZero overwrites files with zeros.
+	ShredFillZero ShredFill = "zero"
+)
+
+// shredChunkSize is the size of the blocks that are written while overwriting a file.
+const shredChunkSize = 64 * 1024
+
+// shredAction represents the action of overwriting old files before deleting them.
+type shredAction struct {
+	action
+	passes int
+	fill   ShredFill
+	rename bool
+}
+
+// newShredAction returns a pointer to a shredAction.
+func newShredAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*shredAction, error) {
+	passes := c.Passes
+	if passes < 0 {
+		return nil, fmt.Errorf("invalid number of passes %d", c.Passes)
+	}
+	if passes == 0 {
+		passes = 1
+	}
+
+	fill := c.Fill
+	switch fill {
+	case "":
+		fill = ShredFillRandom
+	case ShredFillRandom, ShredFillZero:
+	default:
+		return nil, fmt.Errorf("unknown fill %q, expected random or zero", c.Fill)
+	}
+
+	return &shredAction{action{dir, fs, log, pretend}, passes, fill, c.Rename}, nil
+}
+
+// Perform overwrites and deletes files that are past a certain age or certain size.
+func (a shredAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	var newFiles []os.FileInfo
+	var total int64
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Shred] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		if a.pretend {
+			a.log.Printf("[Shred] PRETEND: Would shred file %s (%d bytes, %d passes)", filename, file.Size(), a.passes)
+			continue
+		}
+
+		a.log.Printf("[Shred] Shredding file %s", filename)
+
+		written, err := a.shred(filename)
+		total += written
+		if err != nil {
+			a.log.Printf("[Shred] ERROR: Failed to shred file %s: %s", filename, err)
+			continue
+		}
+	}
+
+	if !a.pretend && total > 0 {
+		a.log.Printf("[Shred] Overwrote %d bytes", total)
+	}
+	return newFiles, nil
+}
+
+// shred overwrites filename, optionally renames it and removes it. It returns the number of bytes written.
+func (a shredAction) shred(filename string) (int64, error) {
+	written, err := a.overwrite(filename)
+	if err != nil {
+		return written, err
+	}
+
+	if a.rename {
+		name := make([]byte, 16)
+		_, err = rand.Read(name)
+		if err != nil {
+			return written, err
+		}
+		renamed := filepath.Join(filepath.Dir(filename), hex.EncodeToString(name))
+		err = a.fs.Rename(filename, renamed)
+		if err != nil {
+			return written, fmt.Errorf("failed to rename file: %v", err)
+		}
+		filename = renamed
+	}
+
+	return written, a.fs.Remove(filename)
+}
+
+// overwrite overwrites the contents of filename once per pass and syncs it to disk after each pass.
+func (a shredAction) overwrite(filename string) (int64, error) {
+	f, err := a.fs.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	var src io.Reader = zeroReader{}
+	if a.fill == ShredFillRandom {
+		src = rand.Reader
+	}
+
+	var written int64
+	buf := make([]byte, shredChunkSize)
+	for pass := 0; pass < a.passes; pass++ {
+		var offset int64
+		for offset < size {
+			chunk := buf
+			if size-offset < int64(len(chunk)) {
+				chunk = chunk[:size-offset]
+			}
+			_, err = io.ReadFull(src, chunk)
+			if err != nil {
+				return written, err
+			}
+			n, err := f.WriteAt(chunk, offset)
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+			offset += int64(n)
+		}
+
+		err = f.Sync()
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// zeroReader is an io.Reader that only returns zeros.
+type zeroReader struct{}
+
+// Read fills p with zeros.
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+package scrubber
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestShred tests that selected files are removed and the overwritten bytes are reported.
+func TestShred(t *testing.T) {
+	for _, rename := range []bool{false, true} {
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "export.csv"), "secret", time.Now())
+		writeTestFile(t, filepath.Join(dir, "small.csv"), "s", time.Now())
+
+		_, out := runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Action: ActionTypeShred, Limit: "1b", Passes: 3, Rename: rename})
+
+		assertMissing(t, filepath.Join(dir, "export.csv"))
+		assertContent(t, filepath.Join(dir, "small.csv"), "s")
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("expected only small.csv to be left, got %d files", len(entries))
+		}
+		if !strings.Contains(out, "Overwrote 18 bytes") {
+			t.Errorf("expected 18 overwritten bytes to be reported, got %q", out)
+		}
+	}
+}
+
+// TestShredOverwrite tests that the file contents are overwritten.
+func TestShredOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.csv")
+	content := strings.Repeat("secret", 20000)
+
+	for _, fill := range []ShredFill{ShredFillZero, ShredFillRandom} {
+		writeTestFile(t, path, content, time.Now())
+
+		a, err := newShredAction(&StrategyConfig{Passes: 2, Fill: fill}, &directory{Path: dir}, OSFilesystem{}, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written, err := a.overwrite(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != int64(2*len(content)) {
+			t.Errorf("expected %d bytes to be written, got %d", 2*len(content), written)
+		}
+
+		overwritten, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(overwritten) != len(content) {
+			t.Errorf("expected the size to stay %d, got %d", len(content), len(overwritten))
+		}
+		if bytes.Contains(overwritten, []byte("secret")) {
+			t.Errorf("expected the content to be overwritten with %s", fill)
+		}
+		if fill == ShredFillZero && !bytes.Equal(overwritten, make([]byte, len(content))) {
+			t.Error("expected the content to be overwritten with zeros")
+		}
+	}
+}
+
+// TestShredPretend tests that files are left untouched in pretend mode.
+func TestShredPretend(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "export.csv"), "secret", time.Now())
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Strategies: []StrategyConfig{{Type: StrategyTypeSize, Action: ActionTypeShred, Limit: "1b"}},
+	}}}
+	err := New(c, OSFilesystem{}, log.New(ioutil.Discard, "", 0), true).Scrub()
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	assertContent(t, filepath.Join(dir, "export.csv"), "secret")
+}
+
+// TestShredInvalid tests that invalid configurations are rejected.
+func TestShredInvalid(t *testing.T) {
+	configs := []StrategyConfig{
+		{Passes: -1},
+		{Fill: "ones"},
+	}
+	for _, c := range configs {
+		c := c
+		_, err := newShredAction(&c, &directory{}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
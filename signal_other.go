@@ -0,0 +1,10 @@
+//go:build !unix
+
+package scrubber
+
+import "os"
+
+// signals maps signal names to the signals hooks can send. Other platforms only support killing a process.
+var signals = map[string]os.Signal{
+	"KILL": os.Kill,
+}
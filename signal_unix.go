@@ -0,0 +1,19 @@
+//go:build unix
+
+package scrubber
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals maps signal names to the signals hooks can send.
+var signals = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
@@ -14,12 +14,12 @@ type sizeStrategy struct {
 }
 
 // newSizeStrategy returns a new sizeStrategy.
-func newSizeStrategy(c *StrategyConfig, dir *directory, action performer, log logger) *sizeStrategy {
+func newSizeStrategy(c *StrategyConfig, dir *directory, action Action, log Logger) *sizeStrategy {
 	return &sizeStrategy{Strategy{c, dir, action, log}, 0}
 }
 
-// process deletes all files greater than a certain size.
-func (s sizeStrategy) process(files []os.FileInfo) ([]os.FileInfo, error) {
+// Select selects files greater than a certain size.
+func (s sizeStrategy) Select(files []os.FileInfo) (checkFn, error) {
 	err := s.unmarshalText([]byte(s.c.Limit))
 	if err != nil {
 		return nil, err
@@ -28,26 +28,34 @@ func (s sizeStrategy) process(files []os.FileInfo) ([]os.FileInfo, error) {
 		return nil, fmt.Errorf("file size limit has to be greater than 0")
 	}
 
-	files, err = s.action.perform(files, func(file os.FileInfo) bool {
+	return func(file os.FileInfo) bool {
 		return file.Size() > s.limit
-	})
-
-	return files, err
+	}, nil
 }
 
 // parseLimit turns a string representation of a filesize into bytes
 func (s *sizeStrategy) unmarshalText(text []byte) error {
+	limit, err := parseSize(text)
+	if err != nil {
+		return err
+	}
+
+	s.limit = limit
+
+	return nil
+}
+
+// parseSize turns a string representation of a filesize like 10MB into bytes.
+func parseSize(text []byte) (int64, error) {
 	if len(text) < 1 {
-		return fmt.Errorf("limit cannot be an empty string")
+		return 0, fmt.Errorf("limit cannot be an empty string")
 	}
 
 	var v datasize.ByteSize
 	err := v.UnmarshalText(text)
 	if err != nil {
-		return fmt.Errorf("invalid size definition")
+		return 0, fmt.Errorf("invalid size definition")
 	}
 
-	s.limit = int64(v)
-
-	return nil
+	return int64(v), nil
 }
@@ -24,7 +24,7 @@ func TestSize(t *testing.T) {
 
 	a := newDeleteAction(&d, fs, logger, false)
 	s := newSizeStrategy(&c, &d, a, logger)
-	_, err := s.process(files)
+	_, err := selectorStrategy{s, a}.process(files)
 	if err != nil {
 		t.Errorf("expected no error, got %v\n", err)
 	}
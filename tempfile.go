@@ -0,0 +1,139 @@
+package scrubber
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempSuffix is appended to the names of files that are still being written.
+const tempSuffix = ".scrubber-tmp"
+
+// tempMaxAge is the age after which temporary files are considered to be left
+// by an interrupted run. Younger files may still be written by another run.
+const tempMaxAge = 24 * time.Hour
+
+// tempPath returns the path of the temporary file path is written to.
+func tempPath(path string) string {
+	return path + tempSuffix
+}
+
+// isTempFile reports whether name is a temporary file of scrubber.
+func isTempFile(name string) bool {
+	return strings.HasSuffix(name, tempSuffix)
+}
+
+// syncClose syncs f to disk and closes it.
+func syncClose(f *os.File) error {
+	err := f.Sync()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync file: %v", err)
+	}
+	return closeAll(f)
+}
+
+// cleanTempFiles removes the temporary files interrupted runs left in dir and
+// in the directories its actions write to, see tempDirs.
+func (s Scrubber) cleanTempFiles(dir *directory) {
+	s.cleanTempDir(dir.Path)
+	for _, path := range s.tempDirs(dir) {
+		s.cleanTempDir(path)
+	}
+}
+
+// cleanTempDir removes the temporary files interrupted runs left in path. Files
+// that have been modified or changed within tempMaxAge are kept. The change time
+// is taken into account as finished files get the modification time of their
+// original right before they are renamed.
+func (s Scrubber) cleanTempDir(path string) {
+	files, err := s.fs.ListFiles(path)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() || !isTempFile(file.Name()) {
+			continue
+		}
+
+		times, err := s.fs.Times(file, path)
+		if err != nil {
+			continue
+		}
+		changed := times.ModTime
+		if times.ChangeTime.After(changed) {
+			changed = times.ChangeTime
+		}
+		if time.Since(changed) < tempMaxAge {
+			continue
+		}
+
+		filename := s.fs.FullPath(file, path)
+		if s.pretend {
+			s.log.Printf("PRETEND: Would remove stale temporary file %s", filename)
+			continue
+		}
+
+		s.log.Printf("Removing stale temporary file %s", filename)
+
+		err = s.fs.Remove(filename)
+		if err != nil {
+			s.log.Printf("[ERROR] Failed to remove stale temporary file %s: %s", filename, err)
+		}
+	}
+}
+
+// tempDirs returns the directories outside of dir the actions of dir write
+// temporary files to: trash directories and their runs as well as the
+// directories of move destinations and bundle archives. Directories that are
+// rendered from a template are unknown before the files are processed, so
+// they are left out.
+func (s Scrubber) tempDirs(dir *directory) []string {
+	var dirs []string
+	for _, c := range dir.Strategies {
+		var template string
+		switch c.Action {
+		case ActionTypeTrash:
+			if c.Trash == "" {
+				continue
+			}
+			trash := c.Trash
+			if !filepath.IsAbs(trash) {
+				trash = filepath.Join(dir.Path, trash)
+			}
+			dirs = append(dirs, trash)
+
+			runs, err := s.fs.ListFiles(trash)
+			if err != nil {
+				continue
+			}
+			for _, run := range runs {
+				if run.Mode().IsDir() {
+					dirs = append(dirs, s.fs.FullPath(run, trash))
+				}
+			}
+			continue
+		case ActionTypeMove:
+			template = c.Destination
+		case ActionTypeBundle:
+			template = c.Archive
+		default:
+			continue
+		}
+
+		path := filepath.Dir(template)
+		if strings.Contains(path, "{{") {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir.Path, path)
+		}
+		if path != dir.Path {
+			dirs = append(dirs, path)
+		}
+	}
+	return dirs
+}
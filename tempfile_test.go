@@ -0,0 +1,173 @@
+package scrubber
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// modTimeFs is a filesystem that does not track change times, so files can be backdated.
+type modTimeFs struct {
+	OSFilesystem
+}
+
+// Times returns only the modification time of file.
+func (fs modTimeFs) Times(file os.FileInfo, dir string) (FileTimes, error) {
+	return FileTimes{ModTime: file.ModTime()}, nil
+}
+
+// TestCleanTempFiles tests that stale temporary files are removed before a directory is scrubbed.
+func TestCleanTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.log.gz"+tempSuffix), "partial", time.Now().Add(-48*time.Hour))
+	writeTestFile(t, filepath.Join(dir, "app.log"), "content", time.Now())
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Strategies: []StrategyConfig{{Type: StrategyTypeSize, Action: ActionTypeDelete, Limit: "1KB"}},
+	}}}
+	err := New(c, modTimeFs{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMissing(t, filepath.Join(dir, "app.log.gz"+tempSuffix))
+	assertContent(t, filepath.Join(dir, "app.log"), "content")
+}
+
+// TestCleanTempFilesOutside tests that temporary files are removed from trash directories and move destinations.
+func TestCleanTempFilesOutside(t *testing.T) {
+	dir := t.TempDir()
+	trash := t.TempDir()
+	dst := t.TempDir()
+	err := os.Mkdir(filepath.Join(trash, "run"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(trash, trashIndexName+tempSuffix), "partial", time.Now().Add(-48*time.Hour))
+	writeTestFile(t, filepath.Join(trash, "run", "app.log"+tempSuffix), "partial", time.Now().Add(-48*time.Hour))
+	writeTestFile(t, filepath.Join(dst, "app.log"+tempSuffix), "partial", time.Now().Add(-48*time.Hour))
+
+	c := &TomlConfig{Directories: []directory{{
+		Path: dir,
+		Strategies: []StrategyConfig{
+			{Type: StrategyTypeSize, Action: ActionTypeTrash, Limit: "1KB", Trash: trash},
+			{Type: StrategyTypeSize, Action: ActionTypeMove, Limit: "1KB", Destination: dst + "/{{.Name}}"},
+		},
+	}}}
+	err = New(c, modTimeFs{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMissing(t, filepath.Join(trash, trashIndexName+tempSuffix))
+	assertMissing(t, filepath.Join(trash, "run", "app.log"+tempSuffix))
+	assertMissing(t, filepath.Join(dst, "app.log"+tempSuffix))
+}
+
+// TestCleanTempFilesRecent tests that temporary files another run may still be writing are kept,
+// even if they have already been backdated to the modification time of their original.
+func TestCleanTempFilesRecent(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.log.gz"+tempSuffix), "partial", time.Now())
+	writeTestFile(t, filepath.Join(dir, "old.log.gz"+tempSuffix), "complete", time.Now().Add(-48*time.Hour))
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Strategies: []StrategyConfig{{Type: StrategyTypeSize, Action: ActionTypeDelete, Limit: "1KB"}},
+	}}}
+	err := New(c, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, filepath.Join(dir, "app.log.gz"+tempSuffix), "partial")
+	if runtime.GOOS != "windows" {
+		assertContent(t, filepath.Join(dir, "old.log.gz"+tempSuffix), "complete")
+	}
+}
+
+// TestArchiveRace tests that archives created while a file is compressed are never replaced.
+func TestArchiveRace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "content", time.Now())
+
+	fs := &racingFs{path: path + ".gz", content: "other"}
+	a, err := newGzipAction(&StrategyConfig{}, &directory{Path: dir}, fs, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = a.gzip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, path+".gz", "other")
+	if _, err := os.Stat(path + ".1.gz"); err != nil {
+		t.Errorf("expected the archive to be written to %s.1.gz, got %v", path, err)
+	}
+	if names := dirNames(t, dir); len(names) != 3 {
+		t.Errorf("expected no temporary file to be left, got %v", names)
+	}
+}
+
+// TestArchiveCollision tests that existing archives are never replaced.
+func TestArchiveCollision(t *testing.T) {
+	tests := []struct {
+		collision CollisionPolicy
+		archive   string
+	}{
+		{"", "app.log.1.gz"},
+		{CollisionSkip, ""},
+		{CollisionFail, ""},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		writeTestFile(t, path, "content", time.Now())
+		writeTestFile(t, path+".gz", "existing", time.Now())
+
+		c := StrategyConfig{Collision: test.collision}
+		a, err := newGzipAction(&c, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = a.gzip(path)
+		if test.collision == CollisionFail && err == nil {
+			t.Errorf("expected an error for %q", test.collision)
+		}
+		if test.collision == CollisionSkip && !errors.Is(err, errArchiveSkipped) {
+			t.Errorf("expected the file to be skipped, got %v", err)
+		}
+
+		assertContent(t, path+".gz", "existing")
+		if test.archive != "" {
+			if _, err := os.Stat(filepath.Join(dir, test.archive)); err != nil {
+				t.Errorf("expected %s to exist for %q, got %v", test.archive, test.collision, err)
+			}
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if isTempFile(entry.Name()) {
+				t.Errorf("expected no temporary file to be left, got %s", entry.Name())
+			}
+		}
+	}
+
+	_, err := newGzipAction(&StrategyConfig{Collision: CollisionOverwrite}, &directory{}, OSFilesystem{}, nil, false)
+	if err == nil {
+		t.Error("expected an error for overwrite")
+	}
+}
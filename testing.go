@@ -15,6 +15,7 @@ type mockedFs struct {
 	files   []os.FileInfo
 	deleted []string
 	created []string
+	usage   DiskUsage
 }
 
 // Remove marks a file as removed on the mocked filesystem.
@@ -34,6 +35,20 @@ func (fs mockedFs) ListFiles(path string) ([]os.FileInfo, error) {
 	return fs.files, nil
 }
 
+// Usage returns the mocked disk usage.
+func (fs mockedFs) Usage(path string) (DiskUsage, error) {
+	return fs.usage, nil
+}
+
+// Times returns the mocked timestamps of a file.
+func (fs mockedFs) Times(file os.FileInfo, dir string) (FileTimes, error) {
+	m, ok := file.(mockedFileInfo)
+	if !ok {
+		return FileTimes{ModTime: file.ModTime()}, nil
+	}
+	return FileTimes{ModTime: m.modTime, AccessTime: m.accessTime, ChangeTime: m.changeTime, BirthTime: m.birthTime}, nil
+}
+
 // Ext returns the file extension for a certain file.
 func (fs mockedFs) Ext(file os.FileInfo) string {
 	return "." + strings.Split(file.Name(), ".")[1]
@@ -42,9 +57,12 @@ func (fs mockedFs) Ext(file os.FileInfo) string {
 // mockedFileInfo is a os.FileInfo implementation used for testing.
 type mockedFileInfo struct {
 	os.FileInfo
-	modTime time.Time
-	size    int64
-	name    string
+	modTime    time.Time
+	accessTime time.Time
+	changeTime time.Time
+	birthTime  time.Time
+	size       int64
+	name       string
 }
 
 // Name returns the mocked name of a file.
@@ -0,0 +1,20 @@
+package scrubber
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Times returns all timestamps of a file.
+func (fs OSFilesystem) Times(file os.FileInfo, dir string) (FileTimes, error) {
+	times := FileTimes{ModTime: file.ModTime()}
+
+	if stat, ok := file.Sys().(*syscall.Stat_t); ok {
+		times.AccessTime = time.Unix(stat.Atimespec.Unix())
+		times.ChangeTime = time.Unix(stat.Ctimespec.Unix())
+		times.BirthTime = time.Unix(stat.Birthtimespec.Unix())
+	}
+
+	return times, nil
+}
@@ -0,0 +1,28 @@
+package scrubber
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Times returns all timestamps of a file. The birth time is read using statx and
+// is only available on kernels and filesystems that support it.
+func (fs OSFilesystem) Times(file os.FileInfo, dir string) (FileTimes, error) {
+	times := FileTimes{ModTime: file.ModTime()}
+
+	if stat, ok := file.Sys().(*syscall.Stat_t); ok {
+		times.AccessTime = time.Unix(stat.Atim.Unix())
+		times.ChangeTime = time.Unix(stat.Ctim.Unix())
+	}
+
+	var statx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, fs.FullPath(file, dir), unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &statx)
+	if err == nil && statx.Mask&unix.STATX_BTIME != 0 {
+		times.BirthTime = time.Unix(statx.Btime.Sec, int64(statx.Btime.Nsec))
+	}
+
+	return times, nil
+}
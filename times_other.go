@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+
+package scrubber
+
+import (
+	"os"
+)
+
+// Times returns the modification time of a file. Other timestamps are not supported on this platform.
+func (fs OSFilesystem) Times(file os.FileInfo, dir string) (FileTimes, error) {
+	return FileTimes{ModTime: file.ModTime()}, nil
+}
@@ -0,0 +1,19 @@
+package scrubber
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Times returns all timestamps of a file. Windows does not track a change time.
+func (fs OSFilesystem) Times(file os.FileInfo, dir string) (FileTimes, error) {
+	times := FileTimes{ModTime: file.ModTime()}
+
+	if attr, ok := file.Sys().(*syscall.Win32FileAttributeData); ok {
+		times.AccessTime = time.Unix(0, attr.LastAccessTime.Nanoseconds())
+		times.BirthTime = time.Unix(0, attr.CreationTime.Nanoseconds())
+	}
+
+	return times, nil
+}
@@ -0,0 +1,383 @@
+package scrubber
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trashIndexName is the name of the index file inside a trash directory.
+const trashIndexName = "index.json"
+
+// defaultTrashGrace is how long files stay in the trash if no grace period is configured.
+const defaultTrashGrace = 30 * 24 * time.Hour
+
+// trashEntry records a single file in the trash.
+type trashEntry struct {
+	// RunID is the ID of the run that moved the file to the trash.
+	RunID string `json:"run_id"`
+	// Original is the absolute path the file was moved from.
+	Original string `json:"original"`
+	// Path is the path of the file relative to the trash directory.
+	Path string `json:"path"`
+	// TrashedAt is the time the file was moved to the trash.
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// newRunID returns a new ID for a run, consisting of its start time and a random suffix.
+func newRunID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// trashAction represents the action of moving old files to a trash directory.
+type trashAction struct {
+	action
+	trash string
+	grace time.Duration
+}
+
+// newTrashAction returns a pointer to a trashAction.
+func newTrashAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*trashAction, error) {
+	if c.Trash == "" {
+		return nil, fmt.Errorf("trash action requires a trash directory")
+	}
+
+	grace := defaultTrashGrace
+	if c.Grace != "" {
+		var err error
+		grace, err = parseAge(c.Grace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grace period: %s", err)
+		}
+	}
+
+	trash := c.Trash
+	if !filepath.IsAbs(trash) {
+		trash = filepath.Join(dir.Path, trash)
+	}
+
+	return &trashAction{action{dir, fs, log, pretend}, trash, grace}, nil
+}
+
+// Perform moves files that are past a certain age or certain size to the trash.
+// The index is written after every file, so no file is left in the trash without
+// an entry that allows to restore and purge it.
+func (a trashAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	entries, err := readTrashIndex(a.fs, a.trash)
+	if err != nil {
+		return files, err
+	}
+
+	runID := a.dir.runID
+	if runID == "" {
+		runID = newRunID()
+	}
+
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Trash] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		if a.pretend {
+			a.log.Printf("[Trash] PRETEND: Would move file %s to trash %s", filename, a.trash)
+			continue
+		}
+
+		a.log.Printf("[Trash] Moving file %s to trash %s", filename, a.trash)
+
+		target, _, err := moveFile(a.fs, filename, filepath.Join(a.trash, runID, file.Name()), CollisionSuffix)
+		if err != nil {
+			a.log.Printf("[Trash] ERROR: Failed to move file %s to trash: %s", filename, err)
+			continue
+		}
+
+		path, _ := filepath.Rel(a.trash, target)
+		entries = append(entries, trashEntry{RunID: runID, Original: absPath(filename), Path: path, TrashedAt: time.Now()})
+
+		err = writeTrashIndex(a.fs, a.trash, entries)
+		if err != nil {
+			// Without an entry, the file could neither be restored nor purged.
+			_, skip, moveErr := moveFile(a.fs, target, filename, CollisionSkip)
+			if moveErr != nil || skip {
+				a.log.Printf("[Trash] ERROR: Failed to move file %s back, it is left in the trash as %s", filename, target)
+			}
+			return files, err
+		}
+	}
+
+	return newFiles, nil
+}
+
+// purgeTrash purges the trash directories of all trash actions of dir. It runs before
+// the directory is scanned, so trash directories are purged even if there are no files to process.
+func (s Scrubber) purgeTrash(dir *directory) {
+	for _, c := range dir.Strategies {
+		c := c
+		if c.Action != ActionTypeTrash {
+			continue
+		}
+
+		a, err := newTrashAction(&c, dir, s.fs, s.log, s.pretend)
+		if err != nil {
+			s.log.Printf("[Trash] ERROR: Invalid trash action for directory %s: %s", dir.Path, err)
+			continue
+		}
+
+		err = a.purgeExpired()
+		if err != nil {
+			s.log.Printf("[Trash] ERROR: Failed to purge trash %s: %s", a.trash, err)
+		}
+	}
+}
+
+// purgeExpired removes all files from the trash whose grace period is over and updates the index.
+func (a trashAction) purgeExpired() error {
+	entries, err := readTrashIndex(a.fs, a.trash)
+	if err != nil {
+		return err
+	}
+
+	kept := a.purge(entries)
+	if a.pretend || len(kept) == len(entries) {
+		return nil
+	}
+	return writeTrashIndex(a.fs, a.trash, kept)
+}
+
+// purge removes all files from the trash whose grace period is over and returns the remaining entries.
+func (a trashAction) purge(entries []trashEntry) []trashEntry {
+	var kept []trashEntry
+	for _, entry := range entries {
+		if time.Since(entry.TrashedAt) < a.grace {
+			kept = append(kept, entry)
+			continue
+		}
+
+		path := filepath.Join(a.trash, entry.Path)
+		if a.pretend {
+			a.log.Printf("[Trash] PRETEND: Would purge file %s", path)
+			kept = append(kept, entry)
+			continue
+		}
+
+		a.log.Printf("[Trash] Purging file %s", path)
+
+		err := a.fs.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			a.log.Printf("[Trash] ERROR: Failed to purge file %s: %s", path, err)
+			kept = append(kept, entry)
+			continue
+		}
+
+		// The run directory is only removed once it is empty.
+		a.fs.Remove(filepath.Dir(path))
+	}
+	return kept
+}
+
+// readTrashIndex reads the index of a trash directory. A missing index is an empty trash.
+func readTrashIndex(fs Filesystem, trash string) ([]trashEntry, error) {
+	f, err := fs.Open(filepath.Join(trash, trashIndexName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trash index: %v", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash index: %v", err)
+	}
+
+	var entries []trashEntry
+	err = json.Unmarshal(content, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trash index %s: %v", filepath.Join(trash, trashIndexName), err)
+	}
+	return entries, nil
+}
+
+// writeTrashIndex replaces the index of a trash directory.
+func writeTrashIndex(fs Filesystem, trash string, entries []trashEntry) error {
+	if entries == nil {
+		entries = []trashEntry{}
+	}
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = fs.MkdirAll(trash, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create trash directory: %v", err)
+	}
+
+	path := filepath.Join(trash, trashIndexName)
+	tmpPath := tempPath(path)
+	out, err := fs.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create trash index: %v", err)
+	}
+
+	_, err = out.Write(content)
+	if err == nil {
+		err = out.Sync()
+	}
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = fs.Rename(tmpPath, path)
+	}
+	if err != nil {
+		fs.Remove(tmpPath)
+		return fmt.Errorf("failed to write trash index: %v", err)
+	}
+	return nil
+}
+
+// RestoreFilter selects the files that are restored from a trash directory. Files
+// have to match all criteria that are set, at least one criterion is required.
+type RestoreFilter struct {
+	// RunID restores all files of a single run.
+	RunID string
+	// Pattern restores all files whose original path matches a filepath.Match pattern.
+	Pattern string
+	// Paths restores the files that were moved from these paths.
+	Paths []string
+}
+
+// matches reports whether entry is selected by the filter.
+func (f RestoreFilter) matches(entry trashEntry) bool {
+	if f.RunID != "" && entry.RunID != f.RunID {
+		return false
+	}
+	if f.Pattern != "" {
+		ok, _ := filepath.Match(f.Pattern, entry.Original)
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Paths) > 0 {
+		for _, path := range f.Paths {
+			if filepath.Clean(path) == entry.Original {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Restore moves all files selected by filter from a trash directory back to their
+// original paths. If a file was trashed more than once, its newest version is restored.
+// Files whose original path exists again are left in the trash. It returns the number
+// of restored files.
+func Restore(fs Filesystem, log Logger, trash string, filter RestoreFilter) (int, error) {
+	if filter.RunID == "" && filter.Pattern == "" && len(filter.Paths) == 0 {
+		return 0, fmt.Errorf("restore requires a run ID, a pattern or a path")
+	}
+	if filter.Pattern != "" {
+		_, err := filepath.Match(filter.Pattern, "")
+		if err != nil {
+			return 0, fmt.Errorf("invalid pattern %q: %v", filter.Pattern, err)
+		}
+	}
+
+	entries, err := readTrashIndex(fs, trash)
+	if err != nil {
+		return 0, err
+	}
+
+	newest := make(map[string]int)
+	for i, entry := range entries {
+		if !filter.matches(entry) {
+			continue
+		}
+		if j, ok := newest[entry.Original]; !ok || entry.TrashedAt.After(entries[j].TrashedAt) {
+			newest[entry.Original] = i
+		}
+	}
+
+	restored := make(map[int]bool)
+	for i, entry := range entries {
+		if newest[entry.Original] != i || !filter.matches(entry) {
+			continue
+		}
+
+		path := filepath.Join(trash, entry.Path)
+		log.Printf("[Restore] Restoring file %s from %s", entry.Original, path)
+
+		_, skip, err := moveFile(fs, path, entry.Original, CollisionSkip)
+		if err != nil {
+			log.Printf("[Restore] ERROR: Failed to restore file %s: %s", entry.Original, err)
+			continue
+		}
+		if skip {
+			log.Printf("[Restore] Skipping file %s, it already exists", entry.Original)
+			continue
+		}
+		fs.Remove(filepath.Dir(path))
+		restored[i] = true
+	}
+
+	if len(restored) == 0 {
+		return 0, nil
+	}
+
+	var kept []trashEntry
+	for i, entry := range entries {
+		if !restored[i] {
+			kept = append(kept, entry)
+		}
+	}
+
+	return len(restored), writeTrashIndex(fs, trash, kept)
+}
+
+// TrashDirs returns the trash directories of all trash actions in the configuration.
+// Relative trash directories are resolved against every directory their path expands to.
+func (c TomlConfig) TrashDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, dir := range c.Directories {
+		for _, strategy := range dir.Strategies {
+			if strategy.Action != ActionTypeTrash || strategy.Trash == "" {
+				continue
+			}
+
+			paths := []string{strategy.Trash}
+			if !filepath.IsAbs(strategy.Trash) {
+				expanded, _ := filepath.Glob(dir.Path)
+				paths = paths[:0]
+				for _, path := range expanded {
+					paths = append(paths, filepath.Join(path, strategy.Trash))
+				}
+			}
+
+			for _, path := range paths {
+				if !seen[path] {
+					seen[path] = true
+					dirs = append(dirs, path)
+				}
+			}
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,212 @@
+package scrubber
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// assertMissing checks that a file does not exist.
+func assertMissing(t *testing.T, path string) {
+	t.Helper()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s to not exist, got %v", path, err)
+	}
+}
+
+// TestTrashRestore tests that trashed files can be restored by run ID, path and pattern.
+func TestTrashRestore(t *testing.T) {
+	dir := t.TempDir()
+	trash := t.TempDir()
+	c := StrategyConfig{Action: ActionTypeTrash, Limit: "1b", Trash: trash}
+	logger := log.New(ioutil.Discard, "", 0)
+
+	writeTestFile(t, filepath.Join(dir, "a.log"), "first", time.Now())
+	writeTestFile(t, filepath.Join(dir, "b.log"), "bb", time.Now())
+	writeTestFile(t, filepath.Join(dir, "c.txt"), "cc", time.Now())
+	runAction(t, OSFilesystem{}, directory{Path: dir, runID: "run1"}, c)
+
+	writeTestFile(t, filepath.Join(dir, "a.log"), "second", time.Now())
+	runAction(t, OSFilesystem{}, directory{Path: dir, runID: "run2"}, c)
+
+	assertMissing(t, filepath.Join(dir, "a.log"))
+	assertContent(t, filepath.Join(trash, "run1", "a.log"), "first")
+	assertContent(t, filepath.Join(trash, "run2", "a.log"), "second")
+
+	n, err := Restore(OSFilesystem{}, logger, trash, RestoreFilter{Pattern: filepath.Join(dir, "*.txt")})
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 restored file, got %d, %v", n, err)
+	}
+	assertContent(t, filepath.Join(dir, "c.txt"), "cc")
+
+	n, err = Restore(OSFilesystem{}, logger, trash, RestoreFilter{Paths: []string{filepath.Join(dir, "a.log")}})
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 restored file, got %d, %v", n, err)
+	}
+	assertContent(t, filepath.Join(dir, "a.log"), "second")
+
+	n, err = Restore(OSFilesystem{}, logger, trash, RestoreFilter{RunID: "run1"})
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 restored file, got %d, %v", n, err)
+	}
+	assertContent(t, filepath.Join(dir, "a.log"), "second")
+	assertContent(t, filepath.Join(dir, "b.log"), "bb")
+	assertContent(t, filepath.Join(trash, "run1", "a.log"), "first")
+
+	entries, err := readTrashIndex(OSFilesystem{}, trash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != filepath.Join("run1", "a.log") {
+		t.Errorf("expected only run1/a.log to be left in the index, got %+v", entries)
+	}
+}
+
+// crashingFs is a filesystem on which the process dies while the second file of dir is moved.
+type crashingFs struct {
+	OSFilesystem
+	dir   string
+	moved int
+}
+
+// Link panics for the second file of dir.
+func (fs *crashingFs) Link(oldname, newname string) error {
+	if filepath.Dir(oldname) == fs.dir {
+		fs.moved++
+		if fs.moved == 2 {
+			panic("crash")
+		}
+	}
+	return fs.OSFilesystem.Link(oldname, newname)
+}
+
+// TestTrashRelative tests that the absolute original paths of files in relative directories
+// are recorded, so they can be restored from any working directory.
+func TestTrashRelative(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	err = os.Mkdir("logs", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join("logs", "a.log"), "aa", time.Now())
+	runAction(t, OSFilesystem{}, directory{Path: "logs", runID: "run1"}, StrategyConfig{Action: ActionTypeTrash, Limit: "1b", Trash: "trash"})
+
+	os.Chdir(wd)
+	n, err := Restore(OSFilesystem{}, log.New(ioutil.Discard, "", 0), filepath.Join(dir, "logs", "trash"), RestoreFilter{Paths: []string{filepath.Join(dir, "logs", "a.log")}})
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 restored file, got %d, %v", n, err)
+	}
+	assertContent(t, filepath.Join(dir, "logs", "a.log"), "aa")
+}
+
+// TestTrashCrash tests that files are recorded in the index right after they have been moved.
+func TestTrashCrash(t *testing.T) {
+	dir := t.TempDir()
+	trash := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.log"), "aa", time.Now())
+	writeTestFile(t, filepath.Join(dir, "b.log"), "bb", time.Now())
+
+	func() {
+		defer func() { recover() }()
+		fs := &crashingFs{dir: dir}
+		runAction(t, fs, directory{Path: dir, runID: "run1"}, StrategyConfig{Action: ActionTypeTrash, Limit: "1b", Trash: trash})
+	}()
+
+	entries, err := readTrashIndex(OSFilesystem{}, trash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := dirNames(t, dir); len(entries) != 1 || len(names) != 1 || entries[0].Original == filepath.Join(dir, names[0]) {
+		t.Errorf("expected the moved file to be in the index, got %+v and %v", entries, names)
+	}
+}
+
+// TestTrashPurge tests that files are purged once their grace period is over, even if there are no files to trash.
+func TestTrashPurge(t *testing.T) {
+	dir := t.TempDir()
+	trash := t.TempDir()
+	fs := OSFilesystem{}
+
+	err := fs.MkdirAll(filepath.Join(trash, "old"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(trash, "old", "old.log"), "old", time.Now())
+	writeTestFile(t, filepath.Join(trash, "old", "recent.log"), "recent", time.Now())
+	err = writeTrashIndex(fs, trash, []trashEntry{
+		{RunID: "old", Original: filepath.Join(dir, "old.log"), Path: filepath.Join("old", "old.log"), TrashedAt: time.Now().Add(-48 * time.Hour)},
+		{RunID: "old", Original: filepath.Join(dir, "recent.log"), Path: filepath.Join("old", "recent.log"), TrashedAt: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &TomlConfig{Directories: []directory{{
+		Path:       dir,
+		Strategies: []StrategyConfig{{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeTrash, Trash: trash, Grace: "1d"}},
+	}}}
+	err = New(c, fs, log.New(ioutil.Discard, "", 0), false).Scrub()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMissing(t, filepath.Join(trash, "old", "old.log"))
+	assertContent(t, filepath.Join(trash, "old", "recent.log"), "recent")
+
+	entries, err := readTrashIndex(fs, trash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Original != filepath.Join(dir, "recent.log") {
+		t.Errorf("expected only recent.log to be left in the index, got %+v", entries)
+	}
+}
+
+// TestRestoreWithoutFilter tests that restoring requires a filter.
+func TestRestoreWithoutFilter(t *testing.T) {
+	_, err := Restore(OSFilesystem{}, log.New(ioutil.Discard, "", 0), t.TempDir(), RestoreFilter{})
+	if err == nil {
+		t.Error("expected an error without a filter")
+	}
+}
+
+// TestTrashDirs tests that relative trash directories are resolved against the expanded directories.
+func TestTrashDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		err := os.Mkdir(filepath.Join(root, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := TomlConfig{Directories: []directory{
+		{Path: filepath.Join(root, "*"), Strategies: []StrategyConfig{{Action: ActionTypeTrash, Trash: ".trash"}}},
+		{Path: root, Strategies: []StrategyConfig{{Action: ActionTypeTrash, Trash: filepath.Join(root, "trash")}, {Action: ActionTypeDelete}}},
+	}}
+
+	dirs := c.TrashDirs()
+	expected := []string{filepath.Join(root, "a", ".trash"), filepath.Join(root, "b", ".trash"), filepath.Join(root, "trash")}
+	if len(dirs) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, dirs)
+	}
+	for i := range expected {
+		if dirs[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, dirs)
+		}
+	}
+}
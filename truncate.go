@@ -0,0 +1,206 @@
+package scrubber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// truncateChunkSize is the size of the blocks that are read while searching and moving the tail of a file.
+const truncateChunkSize = 64 * 1024
+
+// truncateAction represents the action of shrinking files in place to their tail.
+type truncateAction struct {
+	action
+	keepBytes int64
+	keepLines int
+}
+
+// newTruncateAction returns a pointer to a truncateAction.
+func newTruncateAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*truncateAction, error) {
+	if (c.Keep == "") == (c.KeepLines == 0) {
+		return nil, fmt.Errorf("truncate action requires either keep or keep_lines")
+	}
+	if c.KeepLines < 0 {
+		return nil, fmt.Errorf("invalid keep_lines %d", c.KeepLines)
+	}
+
+	var keepBytes int64
+	if c.Keep != "" {
+		var err error
+		keepBytes, err = parseSize([]byte(c.Keep))
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep: %s", err)
+		}
+	}
+
+	return &truncateAction{action{dir, fs, log, pretend}, keepBytes, c.KeepLines}, nil
+}
+
+// Perform truncates files that are past a certain age or certain size.
+func (a truncateAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Truncate] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		if a.pretend {
+			a.log.Printf("[Truncate] PRETEND: Would truncate file %s", filename)
+			continue
+		}
+
+		a.log.Printf("[Truncate] Truncating file %s", filename)
+
+		err := a.truncate(filename)
+		if err != nil {
+			a.log.Printf("[Truncate] ERROR: Failed to truncate file %s: %s", filename, err)
+			continue
+		}
+	}
+	return newFiles, nil
+}
+
+// truncate moves the tail of filename to the beginning of the file and cuts off the rest.
+// The file is modified in place, so processes writing to it keep a valid file handle.
+func (a truncateAction) truncate(filename string) error {
+	f, err := a.fs.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	var start int64
+	if a.keepLines > 0 {
+		start, err = tailLines(f, size, a.keepLines)
+	} else {
+		start, err = tailBytes(f, size, a.keepBytes)
+	}
+	if err != nil {
+		return err
+	}
+	if start == 0 {
+		return nil
+	}
+
+	// Lines appended while the tail is moved are copied as well. The size is
+	// checked again right before the file is cut, so only a write that races
+	// with the truncate call itself can still be lost.
+	var written int64
+	for {
+		n, err := moveRange(f, start+written, written, size-start-written)
+		written += n
+		if err != nil {
+			return err
+		}
+
+		info, err = f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() != size {
+			size = info.Size()
+			continue
+		}
+
+		err = f.Truncate(written)
+		if err != nil {
+			return err
+		}
+		return f.Sync()
+	}
+}
+
+// tailBytes returns the offset of the first line that starts within the last n bytes of a file.
+// If no line starts within them, the last n bytes are kept as they are.
+func tailBytes(f io.ReaderAt, size, n int64) (int64, error) {
+	if size <= n {
+		return 0, nil
+	}
+
+	buf := make([]byte, truncateChunkSize)
+	for offset := size - n - 1; offset < size; offset += truncateChunkSize {
+		read, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.IndexByte(buf[:read], '\n'); i >= 0 && offset+int64(i)+1 < size {
+			return offset + int64(i) + 1, nil
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return size - n, nil
+}
+
+// tailLines returns the offset of the last n lines of a file. A missing newline at the end of the file is ignored.
+func tailLines(f io.ReaderAt, size int64, n int) (int64, error) {
+	buf := make([]byte, truncateChunkSize)
+	end := size
+	found := 0
+	for end > 0 {
+		start := end - truncateChunkSize
+		if start < 0 {
+			start = 0
+		}
+
+		chunk := buf[:end-start]
+		_, err := f.ReadAt(chunk, start)
+		if err != nil {
+			return 0, err
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			found++
+			if found == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+
+	return 0, nil
+}
+
+// moveRange copies n bytes of f from offset src to offset dst. dst has to be lower than src.
+func moveRange(f *os.File, src, dst, n int64) (int64, error) {
+	buf := make([]byte, truncateChunkSize)
+	var moved int64
+	for moved < n {
+		chunk := buf
+		if n-moved < int64(len(chunk)) {
+			chunk = chunk[:n-moved]
+		}
+
+		read, err := f.ReadAt(chunk, src+moved)
+		if err != nil && err != io.EOF {
+			return moved, err
+		}
+		if read == 0 {
+			break
+		}
+
+		_, err = f.WriteAt(chunk[:read], dst+moved)
+		if err != nil {
+			return moved, err
+		}
+		moved += int64(read)
+	}
+	return moved, nil
+}
@@ -0,0 +1,110 @@
+package scrubber
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTruncateBytes tests that the tail is kept starting at a line boundary.
+func TestTruncateBytes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "first\nsecond\nthird\n", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Action: ActionTypeTruncate, Limit: "10b", Keep: "8b"})
+
+	assertContent(t, path, "third\n")
+}
+
+// TestTruncateBytesNoLine tests that the last bytes are kept if they contain no complete line.
+func TestTruncateBytesNoLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "first\n"+strings.Repeat("x", 20), time.Now())
+	other := filepath.Join(dir, "other.log")
+	writeTestFile(t, other, "first\n"+strings.Repeat("y", 20)+"\n", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Action: ActionTypeTruncate, Limit: "10b", Keep: "8b"})
+
+	assertContent(t, path, strings.Repeat("x", 8))
+	assertContent(t, other, strings.Repeat("y", 7)+"\n")
+}
+
+// TestTruncateLines tests that the last lines are kept.
+func TestTruncateLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	lines := make([]string, 0, 20000)
+	for i := 0; i < cap(lines); i++ {
+		lines = append(lines, strings.Repeat("x", i%50))
+	}
+	writeTestFile(t, path, strings.Join(lines, "\n"), time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Action: ActionTypeTruncate, Limit: "1b", KeepLines: 12000})
+
+	assertContent(t, path, strings.Join(lines[8000:], "\n"))
+}
+
+// TestTruncateSmall tests that files smaller than the limit are left untouched.
+func TestTruncateSmall(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "first\nsecond\n", time.Now())
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Action: ActionTypeTruncate, Limit: "1KB", Keep: "1b"})
+
+	assertContent(t, path, "first\nsecond\n")
+}
+
+// TestTruncateInode tests that the file is modified in place and open handles keep working.
+func TestTruncateInode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeTestFile(t, path, "first\nsecond\n", time.Now())
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	runAction(t, OSFilesystem{}, directory{Path: dir}, StrategyConfig{Action: ActionTypeTruncate, Limit: "1b", KeepLines: 1})
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("expected the file to be truncated in place")
+	}
+
+	_, err = f.WriteString("third\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, path, "second\nthird\n")
+}
+
+// TestTruncateInvalid tests that invalid configurations are rejected.
+func TestTruncateInvalid(t *testing.T) {
+	configs := []StrategyConfig{
+		{},
+		{Keep: "1MB", KeepLines: 10},
+		{Keep: "lots"},
+		{KeepLines: -1},
+	}
+	for _, c := range configs {
+		c := c
+		_, err := newTruncateAction(&c, &directory{}, OSFilesystem{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package scrubber
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	// defaultPartSize is the part size of multipart uploads if none is configured.
+	defaultPartSize = 64 << 20
+	// minPartSize is the smallest part size S3 accepts for all but the last part.
+	minPartSize = 5 << 20
+	// maxParts is the maximum number of parts of a multipart upload.
+	maxParts = 10000
+)
+
+// uploadAction represents the action of uploading old files to an S3-compatible bucket.
+type uploadAction struct {
+	action
+	key       *template.Template
+	client    *s3Client
+	partSize  int64
+	collision CollisionPolicy
+}
+
+// newUploadAction returns a pointer to an uploadAction.
+func newUploadAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*uploadAction, error) {
+	if c.S3.Key == "" {
+		return nil, fmt.Errorf("upload action requires an s3 key")
+	}
+
+	key, err := template.New("key").Option("missingkey=error").Parse(c.S3.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 key template: %s", err)
+	}
+
+	partSize := int64(defaultPartSize)
+	if c.S3.PartSize != "" {
+		partSize, err = parseSize([]byte(c.S3.PartSize))
+		if err != nil {
+			return nil, fmt.Errorf("invalid part size: %s", err)
+		}
+		if partSize < minPartSize {
+			return nil, fmt.Errorf("part size must be at least 5MB")
+		}
+	}
+
+	collision, err := validateCollision(c.Collision)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := loadS3Credentials(fs, c.S3)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newS3Client(c.S3, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uploadAction{action{dir, fs, log, pretend}, key, client, partSize, collision}, nil
+}
+
+// Perform uploads files that are past a certain age or certain size and removes them
+// once the bucket has confirmed their content.
+func (a uploadAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	var newFiles []os.FileInfo
+	for _, file := range files {
+		file := file
+		filename := a.fs.FullPath(file, a.dir.Path)
+		if !check(file) {
+			a.log.Printf("[Upload] No action is needed for file %s", filename)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		key, err := a.objectKey(file)
+		if err != nil {
+			return files, err
+		}
+
+		if a.pretend {
+			a.log.Printf("[Upload] PRETEND: Would upload file %s to s3://%s/%s", filename, a.client.bucket, key)
+			continue
+		}
+
+		target, n, skip, err := a.resolveKey(key, 0)
+		if err != nil {
+			return files, err
+		}
+		if skip {
+			a.log.Printf("[Upload] Skipping file %s, s3://%s/%s already exists", filename, a.client.bucket, key)
+			newFiles = append(newFiles, file)
+			continue
+		}
+
+		a.log.Printf("[Upload] Uploading file %s to s3://%s/%s", filename, a.client.bucket, target)
+
+		uploaded, skip, err := a.upload(filename, key, target, n)
+		if err != nil {
+			a.log.Printf("[Upload] ERROR: Failed to upload file %s: %s", filename, err)
+			newFiles = append(newFiles, file)
+			continue
+		}
+		if skip {
+			a.log.Printf("[Upload] Skipping file %s, s3://%s/%s has been created in the meantime", filename, a.client.bucket, target)
+			newFiles = append(newFiles, file)
+			continue
+		}
+		if uploaded != target {
+			a.log.Printf("[Upload] Uploaded file %s to s3://%s/%s instead, s3://%s/%s has been created in the meantime", filename, a.client.bucket, uploaded, a.client.bucket, target)
+		}
+
+		err = a.fs.Remove(filename)
+		if err != nil {
+			a.log.Printf("[Upload] ERROR: Failed to delete original file %s: %s", filename, err)
+		}
+	}
+	return newFiles, nil
+}
+
+// objectKey renders the key template for file.
+func (a uploadAction) objectKey(file os.FileInfo) (string, error) {
+	var key strings.Builder
+	err := a.key.Execute(&key, moveData{
+		Name:    file.Name(),
+		Dir:     a.dir.Path,
+		DirName: filepath.Base(a.dir.Path),
+		ModTime: file.ModTime(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render s3 key: %s", err)
+	}
+
+	return strings.TrimPrefix(key.String(), "/"), nil
+}
+
+// resolveKey returns the key a file should be uploaded to according to the
+// collision policy, starting with the suffix start, and the suffix of that key.
+// If skip is true, the file must not be uploaded at all. Credentials without
+// permission to read the bucket get 403 Forbidden for every key, so such keys
+// are assumed to be free and left to the conditional upload.
+func (a uploadAction) resolveKey(key string, start int) (target string, n int, skip bool, err error) {
+	if a.collision == CollisionOverwrite {
+		return key, 0, false, nil
+	}
+
+	for i := start; i <= maxCollisionSuffix; i++ {
+		target = key
+		if i > 0 {
+			target = collisionName(key, i)
+		}
+
+		exists, err := a.client.headObject(target)
+		var e *s3Error
+		if errors.As(err, &e) && e.statusCode == http.StatusForbidden {
+			return target, i, false, nil
+		}
+		if err != nil {
+			return "", 0, false, err
+		}
+		if !exists {
+			return target, i, false, nil
+		}
+
+		switch a.collision {
+		case CollisionSkip:
+			return "", 0, true, nil
+		case CollisionFail:
+			return "", 0, false, fmt.Errorf("s3://%s/%s already exists", a.client.bucket, key)
+		}
+	}
+
+	return "", 0, false, fmt.Errorf("no free key found for s3://%s/%s", a.client.bucket, key)
+}
+
+// upload uploads filename to target, which resolveKey returned for key with the
+// suffix n. Unless existing objects are overwritten, the upload only succeeds if
+// no object has been created at target in the meantime. Otherwise, the collision
+// policy is applied again. It returns the key the file has been uploaded to.
+func (a uploadAction) upload(filename, key, target string, n int) (string, bool, error) {
+	for {
+		err := a.uploadFile(filename, target)
+		if err == nil {
+			return target, false, nil
+		}
+		if !errors.Is(err, errObjectExists) {
+			return "", false, err
+		}
+
+		switch a.collision {
+		case CollisionSkip:
+			return "", true, nil
+		case CollisionFail:
+			return "", false, fmt.Errorf("s3://%s/%s already exists", a.client.bucket, key)
+		}
+
+		var skip bool
+		target, n, skip, err = a.resolveKey(key, n+1)
+		if err != nil || skip {
+			return "", skip, err
+		}
+	}
+}
+
+// uploadFile streams filename to key. Files larger than the part size are uploaded in
+// parts. Every request carries the MD5 of its content, which is also compared with
+// the returned ETags. The upload fails if the file changes in the meantime. Objects
+// that cannot be verified are removed again.
+func (a uploadAction) uploadFile(filename, key string) error {
+	info, err := a.fs.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+
+	f, err := a.fs.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	if info.Size() <= a.partSize {
+		hash, err := hashPart(io.NewSectionReader(f, 0, info.Size()))
+		if err != nil {
+			return fmt.Errorf("failed to read file: %v", err)
+		}
+		etag, err := a.client.putObject(key, io.NewSectionReader(f, 0, info.Size()), info.Size(), hash, a.collision != CollisionOverwrite)
+		if err != nil {
+			return err
+		}
+		if etag != hash.etag() {
+			a.discard(key)
+			return fmt.Errorf("ETag %s of s3://%s/%s does not match the file", etag, a.client.bucket, key)
+		}
+	} else {
+		err = a.uploadMultipart(f, info.Size(), key)
+		if err != nil {
+			return err
+		}
+	}
+
+	after, err := a.fs.Stat(filename)
+	if err != nil {
+		a.discard(key)
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+	if after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime()) {
+		a.discard(key)
+		return fmt.Errorf("file %s changed while it was uploaded", filename)
+	}
+
+	return nil
+}
+
+// uploadMultipart uploads f in parts of at least the part size. The upload is
+// aborted if a part cannot be uploaded.
+func (a uploadAction) uploadMultipart(f *os.File, size int64, key string) error {
+	partSize := max(a.partSize, (size+maxParts-1)/maxParts)
+
+	uploadID, err := a.client.createMultipartUpload(key)
+	if err != nil {
+		return err
+	}
+
+	var parts []completedPart
+	var sums []byte
+	for offset := int64(0); offset < size; offset += partSize {
+		n := min(partSize, size-offset)
+		number := len(parts) + 1
+
+		var hash partHash
+		var etag string
+		hash, err = hashPart(io.NewSectionReader(f, offset, n))
+		if err == nil {
+			etag, err = a.client.uploadPart(key, uploadID, number, io.NewSectionReader(f, offset, n), n, hash)
+		}
+		if err == nil && etag != hash.etag() {
+			err = fmt.Errorf("ETag %s of part %d does not match the file", etag, number)
+		}
+		if err != nil {
+			a.abort(key, uploadID)
+			return err
+		}
+
+		parts = append(parts, completedPart{number, etag})
+		sums = append(sums, hash.md5...)
+	}
+
+	etag, err := a.client.completeMultipartUpload(key, uploadID, parts, a.collision != CollisionOverwrite)
+	if err != nil {
+		a.abort(key, uploadID)
+		return err
+	}
+
+	// The ETag of a multipart upload is the MD5 of the MD5s of its parts and their number.
+	sum := md5.Sum(sums)
+	expected := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(parts))
+	if etag != expected {
+		a.discard(key)
+		return fmt.Errorf("ETag %s of s3://%s/%s does not match the file", etag, a.client.bucket, key)
+	}
+
+	return nil
+}
+
+// discard removes an object that could not be verified, so it is neither taken for
+// a copy of the file nor pushes the next upload of the file to another key.
+func (a uploadAction) discard(key string) {
+	err := a.client.deleteObject(key)
+	if err != nil {
+		a.log.Printf("[Upload] ERROR: Failed to remove unverified object s3://%s/%s: %s", a.client.bucket, key, err)
+	}
+}
+
+// abort cancels a multipart upload so its parts don't take up space in the bucket.
+func (a uploadAction) abort(key, uploadID string) {
+	err := a.client.abortMultipartUpload(key, uploadID)
+	if err != nil {
+		a.log.Printf("[Upload] ERROR: Failed to abort upload of s3://%s/%s: %s", a.client.bucket, key, err)
+	}
+}
@@ -0,0 +1,375 @@
+package scrubber
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal S3-compatible server storing objects of a single bucket in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	aborted int
+	// badETag makes the server return wrong ETags for objects uploaded at once.
+	badETag bool
+	// failPart makes the upload of a part with this number fail.
+	failPart int
+	// hideOnce makes the server report an existing object with this key as missing once,
+	// as if it was created right after being checked.
+	hideOnce string
+	// forbidHead makes the server deny HEAD requests, like for credentials that may only write.
+	forbidHead bool
+}
+
+// newFakeS3 starts a fakeS3 for the bucket logs and returns its URL.
+func newFakeS3(t *testing.T) (*fakeS3, string) {
+	s := &fakeS3{objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server.URL
+}
+
+// ServeHTTP handles the requests of a single upload, either at once or in parts.
+// Objects are only replaced unless the request carries If-None-Match: *.
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDTEST/") {
+		s.error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/logs/")
+	if !ok {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	sum := md5.Sum(body)
+	if md5Header := r.Header.Get("Content-Md5"); md5Header != "" && md5Header != base64.StdEncoding.EncodeToString(sum[:]) {
+		s.error(w, http.StatusBadRequest, "BadDigest")
+		return
+	}
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	_, exists := s.objects[key]
+	if exists && r.Header.Get("If-None-Match") == "*" && (r.Method == http.MethodPut && uploadID == "" || r.Method == http.MethodPost && uploadID != "") {
+		s.error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead && s.forbidHead:
+		w.WriteHeader(http.StatusForbidden)
+	case r.Method == http.MethodHead:
+		if !exists || key == s.hideOnce {
+			s.hideOnce = ""
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && uploadID == "":
+		s.objects[key] = body
+		if s.badETag {
+			etag = `"0"`
+		}
+		w.Header().Set("ETag", etag)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = fmt.Sprintf("upload-%d", len(s.uploads)+1)
+		s.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case r.Method == http.MethodPut:
+		var number int
+		fmt.Sscan(query.Get("partNumber"), &number)
+		if number == s.failPart {
+			s.error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		s.uploads[uploadID][number] = body
+		w.Header().Set("ETag", etag)
+	case r.Method == http.MethodPost:
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		xml.Unmarshal(body, &complete)
+		var content, sums []byte
+		for _, part := range complete.Parts {
+			data := s.uploads[uploadID][part.PartNumber]
+			sum := md5.Sum(data)
+			content = append(content, data...)
+			sums = append(sums, sum[:]...)
+		}
+		s.objects[key] = content
+		delete(s.uploads, uploadID)
+		sum := md5.Sum(sums)
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><ETag>"%s-%d"</ETag></CompleteMultipartUploadResult>`, hex.EncodeToString(sum[:]), len(complete.Parts))
+	case r.Method == http.MethodDelete && uploadID == "":
+		delete(s.objects, key)
+	case r.Method == http.MethodDelete:
+		delete(s.uploads, uploadID)
+		s.aborted++
+	}
+}
+
+// error writes an S3 error document.
+func (s *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// newTestUpload returns an upload action for the fake server and all files in dir.
+func newTestUpload(t *testing.T, dir, endpoint string, collision CollisionPolicy) (*uploadAction, []os.FileInfo) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	c := StrategyConfig{S3: S3Config{Endpoint: endpoint, Bucket: "logs", Key: "{{.DirName}}/{{.ModTime.Format \"2006\"}}/{{.Name}}", PathStyle: true}, Collision: collision}
+	a, err := newUploadAction(&c, &directory{Path: dir}, OSFilesystem{}, log.New(ioutil.Discard, "", 0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []os.FileInfo
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, info)
+	}
+	return a, files
+}
+
+// runUpload uploads all files in dir to the fake server.
+func runUpload(t *testing.T, dir, endpoint string, partSize int64) []os.FileInfo {
+	t.Helper()
+
+	a, files := newTestUpload(t, dir, endpoint, "")
+	if partSize > 0 {
+		a.partSize = partSize
+	}
+
+	remaining, err := a.Perform(files, func(os.FileInfo) bool { return true })
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	return remaining
+}
+
+// TestUpload tests that uploaded files are stored under their key and removed afterwards.
+func TestUpload(t *testing.T) {
+	s, endpoint := newFakeS3(t)
+	dir := t.TempDir()
+	modTime := time.Date(2023, 6, 15, 12, 0, 0, 0, time.Local)
+	writeTestFile(t, filepath.Join(dir, "app log.txt"), "line 1\nline 2\n", modTime)
+	writeTestFile(t, filepath.Join(dir, "empty.log"), "", modTime)
+
+	remaining := runUpload(t, dir, endpoint, 0)
+	if len(remaining) != 0 {
+		t.Errorf("expected all files to be uploaded, got %d remaining", len(remaining))
+	}
+
+	prefix := filepath.Base(dir) + "/2023/"
+	if string(s.objects[prefix+"app log.txt"]) != "line 1\nline 2\n" {
+		t.Errorf("expected the content of app log.txt to be uploaded, got %v", s.objects)
+	}
+	if content, ok := s.objects[prefix+"empty.log"]; !ok || len(content) != 0 {
+		t.Errorf("expected empty.log to be uploaded, got %v", s.objects)
+	}
+	assertMissing(t, filepath.Join(dir, "app log.txt"))
+	assertMissing(t, filepath.Join(dir, "empty.log"))
+}
+
+// TestUploadMultipart tests that large files are uploaded in parts.
+func TestUploadMultipart(t *testing.T) {
+	s, endpoint := newFakeS3(t)
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.log"), "0123456789", time.Now())
+
+	remaining := runUpload(t, dir, endpoint, 4)
+	if len(remaining) != 0 {
+		t.Errorf("expected the file to be uploaded, got %d remaining", len(remaining))
+	}
+
+	key := filepath.Base(dir) + "/" + time.Now().Format("2006") + "/app.log"
+	if string(s.objects[key]) != "0123456789" {
+		t.Errorf("expected the parts to be assembled, got %q", s.objects[key])
+	}
+	if len(s.uploads) != 0 || s.aborted != 0 {
+		t.Errorf("expected the upload to be completed, got %d open and %d aborted uploads", len(s.uploads), s.aborted)
+	}
+	assertMissing(t, filepath.Join(dir, "app.log"))
+}
+
+// TestUploadVerify tests that files are kept and objects are removed if the upload cannot be verified.
+func TestUploadVerify(t *testing.T) {
+	s, endpoint := newFakeS3(t)
+	s.badETag = true
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.log"), "content", time.Now())
+
+	remaining := runUpload(t, dir, endpoint, 0)
+	if len(remaining) != 1 {
+		t.Errorf("expected the file to be kept, got %d remaining", len(remaining))
+	}
+	if len(s.objects) != 0 {
+		t.Errorf("expected the unverified object to be removed, got %v", s.objects)
+	}
+	assertContent(t, filepath.Join(dir, "app.log"), "content")
+
+	// The next run uploads to the same key again.
+	s.badETag = false
+	remaining = runUpload(t, dir, endpoint, 0)
+	key := filepath.Base(dir) + "/" + time.Now().Format("2006") + "/app.log"
+	if len(remaining) != 0 || len(s.objects) != 1 || string(s.objects[key]) != "content" {
+		t.Errorf("expected the file to be uploaded to %s, got %d remaining and %v", key, len(remaining), s.objects)
+	}
+
+	// Failing parts abort the multipart upload.
+	writeTestFile(t, filepath.Join(dir, "app.log"), "content", time.Now())
+	s.objects = make(map[string][]byte)
+	s.failPart = 2
+	remaining = runUpload(t, dir, endpoint, 4)
+	if len(remaining) != 1 {
+		t.Errorf("expected the file to be kept, got %d remaining", len(remaining))
+	}
+	if s.aborted != 1 || len(s.uploads) != 0 {
+		t.Errorf("expected the upload to be aborted, got %d open and %d aborted uploads", len(s.uploads), s.aborted)
+	}
+	assertContent(t, filepath.Join(dir, "app.log"), "content")
+}
+
+// TestUploadCollision tests that existing objects are handled using the collision policy,
+// even if they are created after they have been checked.
+func TestUploadCollision(t *testing.T) {
+	tests := []struct {
+		policy    CollisionPolicy
+		race      bool
+		partSize  int64
+		expected  map[string]string
+		remaining bool
+	}{
+		{"", false, 0, map[string]string{"app.log": "old", "app.1.log": "new"}, false},
+		{"", true, 0, map[string]string{"app.log": "old", "app.1.log": "new"}, false},
+		{"", true, 2, map[string]string{"app.log": "old", "app.1.log": "new"}, false},
+		{CollisionSkip, false, 0, map[string]string{"app.log": "old"}, true},
+		{CollisionSkip, true, 2, map[string]string{"app.log": "old"}, true},
+		{CollisionOverwrite, false, 0, map[string]string{"app.log": "new"}, false},
+		{CollisionFail, false, 0, map[string]string{"app.log": "old"}, true},
+		{CollisionFail, true, 0, map[string]string{"app.log": "old"}, true},
+	}
+
+	for _, test := range tests {
+		s, endpoint := newFakeS3(t)
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "app.log"), "new", time.Now())
+		prefix := filepath.Base(dir) + "/" + time.Now().Format("2006") + "/"
+		s.objects[prefix+"app.log"] = []byte("old")
+		if test.race {
+			s.hideOnce = prefix + "app.log"
+		}
+
+		a, files := newTestUpload(t, dir, endpoint, test.policy)
+		if test.partSize > 0 {
+			a.partSize = test.partSize
+		}
+		remaining, err := a.Perform(files, func(os.FileInfo) bool { return true })
+		if (err != nil) != (test.policy == CollisionFail && !test.race) {
+			t.Errorf("%s, race %t: unexpected error %v", test.policy, test.race, err)
+		}
+
+		for name, content := range test.expected {
+			if string(s.objects[prefix+name]) != content {
+				t.Errorf("%s, race %t: expected %s to contain %q, got %q", test.policy, test.race, name, content, s.objects[prefix+name])
+			}
+		}
+		if len(s.objects) != len(test.expected) || len(s.uploads) != 0 {
+			t.Errorf("%s, race %t: expected %d objects and no open uploads, got %d and %d", test.policy, test.race, len(test.expected), len(s.objects), len(s.uploads))
+		}
+		if test.remaining {
+			assertContent(t, filepath.Join(dir, "app.log"), "new")
+		} else {
+			assertMissing(t, filepath.Join(dir, "app.log"))
+		}
+		if err == nil && (len(remaining) == 1) != test.remaining {
+			t.Errorf("%s, race %t: expected the file to remain: %t", test.policy, test.race, test.remaining)
+		}
+	}
+}
+
+// TestUploadForbiddenHead tests that credentials which may not read the bucket rely on the
+// conditional upload to find a free key.
+func TestUploadForbiddenHead(t *testing.T) {
+	for _, partSize := range []int64{0, 2} {
+		s, endpoint := newFakeS3(t)
+		s.forbidHead = true
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, "app.log"), "new", time.Now())
+		prefix := filepath.Base(dir) + "/" + time.Now().Format("2006") + "/"
+		s.objects[prefix+"app.log"] = []byte("old")
+		s.objects[prefix+"app.1.log"] = []byte("older")
+
+		remaining := runUpload(t, dir, endpoint, partSize)
+		if len(remaining) != 0 {
+			t.Errorf("part size %d: expected the file to be uploaded, got %d remaining", partSize, len(remaining))
+		}
+		if string(s.objects[prefix+"app.log"]) != "old" || string(s.objects[prefix+"app.2.log"]) != "new" {
+			t.Errorf("part size %d: expected the file to be uploaded to app.2.log, got %v", partSize, s.objects)
+		}
+	}
+}
+
+// TestUploadConfig tests that invalid upload settings are rejected.
+func TestUploadConfig(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	dir := &directory{Path: t.TempDir()}
+	logger := log.New(ioutil.Discard, "", 0)
+
+	invalid := []S3Config{
+		{Bucket: "logs"},
+		{Bucket: "logs", Key: "{{.Name"},
+		{Key: "{{.Name}}"},
+		{Bucket: "logs", Key: "{{.Name}}", PartSize: "1MB"},
+		{Bucket: "logs", Key: "{{.Name}}", Endpoint: "localhost:9000"},
+		{Bucket: "logs", Key: "{{.Name}}", Credentials: filepath.Join(dir.Path, "missing")},
+		{Bucket: "logs", Key: "{{.Name}}", Timeout: "10"},
+	}
+	for _, s3 := range invalid {
+		c := StrategyConfig{S3: s3}
+		_, err := newUploadAction(&c, dir, OSFilesystem{}, logger, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", s3)
+		}
+	}
+	for timeout, expected := range map[string]time.Duration{"": defaultS3Timeout, "30m": 30 * time.Minute} {
+		c := StrategyConfig{S3: S3Config{Bucket: "logs", Key: "{{.Name}}", Timeout: timeout}}
+		a, err := newUploadAction(&c, dir, OSFilesystem{}, logger, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.client.http.Timeout != expected {
+			t.Errorf("expected a timeout of %s for %q, got %s", expected, timeout, a.client.http.Timeout)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package scrubber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ulikunitz/xz"
+)
+
+// xzDictCaps maps the xz compression levels to the dictionary sizes of the
+// presets of the xz command line tool. The dictionary size is the only setting
+// that varies, so levels 3 and 4 as well as 5 and 6 produce identical output.
+// The binary tree match finder xz uses from level 4 is too slow in the xz
+// package to be used instead.
+var xzDictCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// xzAction represents the action of compressing old files using xz.
+type xzAction struct {
+	action
+	config  xz.WriterConfig
+	threads int
+	opts    archiveOptions
+}
+
+// newXzAction returns a pointer to a xzAction. A level of 0 uses the default level 6.
+func newXzAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*xzAction, error) {
+	if c.Level < 0 || c.Level > 9 {
+		return nil, fmt.Errorf("invalid xz level %d, expected 1 to 9 or 0 for the default level", c.Level)
+	}
+	if c.Threads < 0 {
+		return nil, fmt.Errorf("threads cannot be negative")
+	}
+
+	level := c.Level
+	if level == 0 {
+		level = 6
+	}
+
+	config := xz.WriterConfig{DictCap: xzDictCaps[level], CheckSum: xz.CRC64}
+	if err := config.Verify(); err != nil {
+		return nil, err
+	}
+
+	opts, err := newArchiveOptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xzAction{action{dir, fs, log, pretend}, config, max(c.Threads, 1), opts}, nil
+}
+
+// Perform compresses files that are past a certain age or certain size.
+func (a xzAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	return a.performArchive(files, check, "xz", a.xz)
+}
+
+// xz creates a .xz file next to filePath. The xz format has no room for
+// metadata, so the absolute path is stored in a path file next to it.
+func (a xzAction) xz(filePath string) error {
+	opts := a.opts
+	opts.pathFile = true
+	return a.compressFile(filePath, ".xz", opts, func(dst io.Writer, src io.Reader, info os.FileInfo) error {
+		if a.threads > 1 {
+			return a.compressParallel(dst, src)
+		}
+		return a.compress(dst, src)
+	}, func(archive io.Reader) (io.ReadCloser, error) {
+		r, err := xz.NewReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	})
+}
+
+// compress writes src as a single xz stream to dst.
+func (a xzAction) compress(dst io.Writer, src io.Reader) error {
+	w, err := a.config.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, src)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// xzBlock is a part of a file that is compressed independently.
+type xzBlock struct {
+	out  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+// compressParallel splits src into blocks of three times the dictionary size,
+// like xz does in multi-threaded mode, and compresses them concurrently. Each
+// block is written as a separate xz stream. Concatenated streams are valid xz
+// files that all common tools decompress.
+func (a xzAction) compressParallel(dst io.Writer, src io.Reader) error {
+	blockSize := 3 * a.config.DictCap
+	queue := make(chan *xzBlock, a.threads-1)
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(queue)
+		for {
+			select {
+			case <-stop:
+				readErr <- nil
+				return
+			default:
+			}
+
+			data := make([]byte, blockSize)
+			n, err := io.ReadFull(src, data)
+			if n > 0 {
+				block := &xzBlock{done: make(chan struct{})}
+				go func() {
+					defer close(block.done)
+					block.err = a.compress(&block.out, bytes.NewReader(data[:n]))
+				}()
+				select {
+				case queue <- block:
+				case <-stop:
+					<-block.done
+					readErr <- nil
+					return
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var blocks int
+	var err error
+	for block := range queue {
+		<-block.done
+		blocks++
+		err = block.err
+		if err == nil {
+			_, err = dst.Write(block.out.Bytes())
+		}
+		if err != nil {
+			close(stop)
+			break
+		}
+	}
+	if err != nil {
+		// The reader still uses src, which is closed once we return. Wait for
+		// it and all blocks that are still being compressed.
+		for block := range queue {
+			<-block.done
+		}
+		<-readErr
+		return err
+	}
+
+	err = <-readErr
+	if err != nil {
+		return err
+	}
+
+	if blocks == 0 {
+		return a.compress(dst, bytes.NewReader(nil))
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package scrubber
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+// TestXz tests if files are compressed using xz and removed correctly, with and without threads.
+func TestXz(t *testing.T) {
+	content := bytes.Repeat([]byte("2023-06-15 12:00:00 GET /index.html 200\n"), 200000)
+
+	for _, threads := range []int{0, 4} {
+		dir := t.TempDir()
+		err := os.WriteFile(dir+"/app.log", content, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs := OSFilesystem{}
+		files, err := fs.ListFiles(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeXz, Level: 1, Threads: threads}
+		d := directory{Path: dir}
+
+		logger := log.New(ioutil.Discard, "", 0)
+
+		a, err := newXzAction(&c, &d, fs, logger, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newSizeStrategy(&c, &d, a, logger)
+		_, err = selectorStrategy{s, a}.process(files)
+		if err != nil {
+			t.Errorf("threads %d: expected no error from process, got %v\n", threads, err)
+		}
+
+		if _, err := os.Stat(dir + "/app.log"); !os.IsNotExist(err) {
+			t.Errorf("threads %d: expected the original file to be removed, got %v", threads, err)
+		}
+
+		f, err := os.Open(dir + "/app.log.xz")
+		if err != nil {
+			t.Fatalf("threads %d: expected app.log.xz to exist, got %v", threads, err)
+		}
+		r, err := xz.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, content) {
+			t.Errorf("threads %d: decompressed content does not match the original", threads)
+		}
+		assertContent(t, dir+"/app.log.xz"+pathFileExt, dir+"/app.log\n")
+	}
+}
+
+// TestXzEmptyFile tests that empty files result in a valid xz file when using threads.
+func TestXzEmptyFile(t *testing.T) {
+	a, err := newXzAction(&StrategyConfig{Threads: 2}, &directory{Path: testPath}, &mockedFs{}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = a.compressParallel(&out, bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := xz.NewReader(&out)
+	if err != nil {
+		t.Fatalf("expected a valid xz stream, got %v", err)
+	}
+	if got, err := io.ReadAll(r); err != nil || len(got) != 0 {
+		t.Errorf("expected empty content, got %q and %v", got, err)
+	}
+}
+
+// TestInvalidXzConfig tests that invalid levels and threads are being rejected.
+func TestInvalidXzConfig(t *testing.T) {
+	for _, c := range []StrategyConfig{{Level: -1}, {Level: 10}, {Threads: -1}} {
+		c := c
+		_, err := newXzAction(&c, &directory{Path: testPath}, &mockedFs{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// watchedReader records reads that happen after the reader has been released.
+type watchedReader struct {
+	r        io.Reader
+	released atomic.Bool
+	late     atomic.Bool
+}
+
+func (w *watchedReader) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	if w.released.Load() {
+		w.late.Store(true)
+	}
+	return w.r.Read(p[:min(len(p), 4096)])
+}
+
+// TestXzParallelWriteError tests that the source is no longer read once a failed parallel compression returns.
+func TestXzParallelWriteError(t *testing.T) {
+	c := StrategyConfig{Level: 1, Threads: 4}
+	a, err := newXzAction(&c, &directory{Path: testPath}, &mockedFs{}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &watchedReader{r: bytes.NewReader(bytes.Repeat([]byte("line\n"), 1<<20))}
+	err = a.compressParallel(failingWriter{}, src)
+	src.released.Store(true)
+	if err == nil {
+		t.Errorf("expected the write error to be returned")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if src.late.Load() {
+		t.Errorf("expected the source not to be read after compressParallel returned")
+	}
+}
@@ -10,81 +10,81 @@ import (
 // zipAction represents the action of zipping up old files.
 type zipAction struct {
 	action
+	opts archiveOptions
 }
 
-// newZipAction returns a pointer to a deleteAction.
-func newZipAction(dir *directory, fs Filesystem, log logger, pretend bool) *zipAction {
-	return &zipAction{action{dir, fs, log, pretend}}
+// newZipAction returns a pointer to a zipAction.
+func newZipAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*zipAction, error) {
+	opts, err := newArchiveOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.recipients) > 0 {
+		return nil, fmt.Errorf("zip archives cannot be encrypted, use gzip, zstd, xz or a tar bundle instead")
+	}
+
+	return &zipAction{action{dir, fs, log, pretend}, opts}, nil
 }
 
-// perform zips files that are past a certain age or certain size.
-func (a zipAction) perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
-	var newFiles []os.FileInfo
-	for _, file := range files {
-		file := file
-		filename := a.fs.FullPath(file, a.dir.Path)
+// Perform zips files that are past a certain age or certain size.
+func (a zipAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	return a.performArchive(files, check, "zip", a.zip)
+}
 
-		if check(file) {
-			if a.pretend {
-				a.log.Printf("[ZIP] PRETEND: Would zip file %s", filename)
-				continue
-			}
+// zip creates a zip file containing a single file. The absolute path of the file is stored as archive comment.
+func (a zipAction) zip(filePath string) error {
+	return a.compressFile(filePath, ".zip", a.opts, func(dst io.Writer, src io.Reader, info os.FileInfo) error {
+		zipWriter := zip.NewWriter(dst)
 
-			a.log.Printf("[ZIP] Zipping file %s", filename)
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fmt.Errorf("failed to create zip header: %v", err)
+		}
+		header.Method = zip.Deflate
 
-			err := a.zip(filename)
-			if err != nil {
-				return files, err
-			}
-			err = a.fs.Remove(filename)
-			if err != nil {
-				a.log.Printf("[ZIP] ERROR: Failed to delete original file %s: %s", filename, err)
-				continue
-			}
-		} else {
-			a.log.Printf("[ZIP] No action is needed for file %s", filename)
-			newFiles = append(newFiles, file)
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			zipWriter.Close()
+			return fmt.Errorf("failed to create zip writer: %v", err)
 		}
-	}
-	return newFiles, nil
-}
 
-// zip creates a zip file containing a single file.
-func (a zipAction) zip(filePath string) error {
-	zipName := filePath + ".zip"
-	zipFile, err := a.fs.Create(zipName)
-	if err != nil {
-		return fmt.Errorf("failed to create zip file: %v", err)
-	}
-	defer zipFile.Close()
+		_, err = io.Copy(writer, src)
+		if err != nil {
+			zipWriter.Close()
+			return err
+		}
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+		err = zipWriter.SetComment(absPath(filePath))
+		if err != nil {
+			zipWriter.Close()
+			return err
+		}
 
-	info, err := a.fs.Stat(filePath)
-	if err != nil {
-		return nil
-	}
+		return zipWriter.Close()
+	}, unzipSingle)
+}
 
-	header, err := zip.FileInfoHeader(info)
-	if err != nil {
-		return fmt.Errorf("failed to create zip header: %v", err)
+// unzipSingle opens the only entry of a zip archive. The CRC32 stored in the
+// archive is checked by the zip reader once the entry has been read completely.
+// Zip archives can only be read from files.
+func unzipSingle(r io.Reader) (io.ReadCloser, error) {
+	archive, ok := r.(*os.File)
+	if !ok {
+		return nil, fmt.Errorf("zip archives can only be read from files")
 	}
 
-	header.Method = zip.Deflate
-
-	writer, err := zipWriter.CreateHeader(header)
+	info, err := archive.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to create zip writer: %v", err)
+		return nil, err
 	}
 
-	file, err := a.fs.Open(filePath)
-	defer file.Close()
+	zipReader, err := zip.NewReader(archive, info.Size())
 	if err != nil {
-		return fmt.Errorf("failed to open zip file: %v", err)
+		return nil, err
+	}
+	if len(zipReader.File) != 1 {
+		return nil, fmt.Errorf("expected a single entry, got %d", len(zipReader.File))
 	}
 
-	_, err = io.Copy(writer, file)
-
-	return err
+	return zipReader.File[0].Open()
 }
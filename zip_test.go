@@ -1,14 +1,65 @@
 package scrubber
 
 import (
+	"archive/zip"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestZip tests if the file is zipped and removed correctly.
 func TestZip(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "filename.extension"), "some content", time.Now())
+
+	fs := OSFilesystem{}
+	files, err := fs.ListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := StrategyConfig{Type: StrategyTypeSize, Limit: "10b", Action: ActionTypeZip}
+	d := directory{Path: dir}
+
+	logger := log.New(ioutil.Discard, "", 0)
+
+	a, err := newZipAction(&c, &d, fs, logger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSizeStrategy(&c, &d, a, logger)
+	_, err = selectorStrategy{s, a}.process(files)
+	if err != nil {
+		t.Errorf("expected no from process error, got %v\n", err)
+	}
+
+	assertMissing(t, filepath.Join(dir, "filename.extension"))
+
+	zr, err := zip.OpenReader(filepath.Join(dir, "filename.extension.zip"))
+	if err != nil {
+		t.Fatalf("expected filename.extension.zip to exist, got %v", err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 1 || zr.File[0].Name != "filename.extension" {
+		t.Fatalf("expected a single entry filename.extension, got %v", zr.File)
+	}
+	r, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil || string(content) != "some content" {
+		t.Errorf("expected the entry to contain %q, got %q (%v)", "some content", content, err)
+	}
+}
+
+// TestZipMissingFile tests that nothing is removed if the file cannot be read.
+func TestZipMissingFile(t *testing.T) {
 	files := []os.FileInfo{
 		mockedFileInfo{name: "filename.extension", size: 20},
 	}
@@ -19,14 +70,17 @@ func TestZip(t *testing.T) {
 
 	logger := log.New(ioutil.Discard, "", 0)
 
-	a := newZipAction(&d, fs, logger, false)
-	s := newSizeStrategy(&c, &d, a, logger)
-	_, err := s.process(files)
+	a, err := newZipAction(&c, &d, fs, logger, false)
 	if err != nil {
-		t.Errorf("expected no from process error, got %v\n", err)
+		t.Fatal(err)
+	}
+	s := newSizeStrategy(&c, &d, a, logger)
+	_, err = selectorStrategy{s, a}.process(files)
+	if err == nil {
+		t.Error("expected an error from process")
 	}
 
-	if len(fs.created) != 1 || fs.created[0] != testPath+"/filename.extension.zip" {
-		t.Errorf("expected only \"/logs/filename.extension.zip\" to exist got %v.\n", fs.created)
+	if len(fs.deleted) != 0 || len(fs.created) != 0 {
+		t.Errorf("expected no file to be created or deleted, got %v and %v", fs.created, fs.deleted)
 	}
 }
@@ -0,0 +1,90 @@
+package scrubber
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdAction represents the action of compressing old files using zstd.
+type zstdAction struct {
+	action
+	level   zstd.EncoderLevel
+	threads int
+	opts    archiveOptions
+}
+
+// newZstdAction returns a pointer to a zstdAction. The level uses the scale of
+// the zstd command line tool from 1 to 22, a level of 0 uses the default level.
+// The encoder only supports four levels, so 1-2, 3-5, 6-9 and 10-22 are identical.
+func newZstdAction(c *StrategyConfig, dir *directory, fs Filesystem, log Logger, pretend bool) (*zstdAction, error) {
+	if c.Level < 0 || c.Level > 22 {
+		return nil, fmt.Errorf("invalid zstd level %d, expected 1 to 22 or 0 for the default level", c.Level)
+	}
+	if c.Threads < 0 {
+		return nil, fmt.Errorf("threads cannot be negative")
+	}
+
+	opts, err := newArchiveOptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	level := zstd.SpeedDefault
+	if c.Level > 0 {
+		level = zstd.EncoderLevelFromZstd(c.Level)
+	}
+
+	return &zstdAction{action{dir, fs, log, pretend}, level, max(c.Threads, 1), opts}, nil
+}
+
+// Perform compresses files that are past a certain age or certain size.
+func (a zstdAction) Perform(files []os.FileInfo, check checkFn) ([]os.FileInfo, error) {
+	return a.performArchive(files, check, "zstd", a.zstd)
+}
+
+// zstd creates a .zst file next to filePath. The absolute path of the file is
+// stored in a skippable frame in front of the compressed data.
+func (a zstdAction) zstd(filePath string) error {
+	return a.compressFile(filePath, ".zst", a.opts, func(dst io.Writer, src io.Reader, info os.FileInfo) error {
+		err := writeSkippableFrame(dst, []byte(absPath(filePath)))
+		if err != nil {
+			return err
+		}
+
+		encoder, err := zstd.NewWriter(dst, zstd.WithEncoderLevel(a.level), zstd.WithEncoderConcurrency(a.threads))
+		if err != nil {
+			return err
+		}
+
+		_, err = encoder.ReadFrom(src)
+		if err != nil {
+			encoder.Close()
+			return err
+		}
+
+		return encoder.Close()
+	}, func(archive io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	})
+}
+
+// zstdSkippableMagic is the magic number of the skippable frame scrubber writes metadata to.
+const zstdSkippableMagic = 0x184D2A5E
+
+// writeSkippableFrame writes data as a skippable frame that zstd decoders ignore.
+func writeSkippableFrame(w io.Writer, data []byte) error {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header[0:4], zstdSkippableMagic)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(data)))
+
+	_, err := w.Write(append(header, data...))
+	return err
+}
@@ -0,0 +1,94 @@
+package scrubber
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestZstd tests if files are compressed using zstd and removed correctly.
+func TestZstd(t *testing.T) {
+	content := bytes.Repeat([]byte("2023-06-15 12:00:00 GET /index.html 200\n"), 10000)
+	modTime := time.Now().AddDate(0, 0, -3).Truncate(time.Second)
+
+	for _, threads := range []int{0, 4} {
+		dir := t.TempDir()
+		err := os.WriteFile(dir+"/app.log", content, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(dir+"/app.log", modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs := OSFilesystem{}
+		files, err := fs.ListFiles(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := StrategyConfig{Type: StrategyTypeSize, Limit: "1b", Action: ActionTypeZstd, Level: 19, Threads: threads}
+		d := directory{Path: dir}
+
+		logger := log.New(ioutil.Discard, "", 0)
+
+		a, err := newZstdAction(&c, &d, fs, logger, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newSizeStrategy(&c, &d, a, logger)
+		_, err = selectorStrategy{s, a}.process(files)
+		if err != nil {
+			t.Errorf("threads %d: expected no error from process, got %v\n", threads, err)
+		}
+
+		if _, err := os.Stat(dir + "/app.log"); !os.IsNotExist(err) {
+			t.Errorf("threads %d: expected the original file to be removed, got %v", threads, err)
+		}
+
+		info, err := os.Stat(dir + "/app.log.zst")
+		if err != nil {
+			t.Fatalf("threads %d: expected app.log.zst to exist, got %v", threads, err)
+		}
+		if !info.ModTime().Equal(modTime) {
+			t.Errorf("threads %d: expected mtime %s, got %s", threads, modTime, info.ModTime())
+		}
+
+		f, err := os.Open(dir + "/app.log.zst")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := zstd.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, content) {
+			t.Errorf("threads %d: decompressed content does not match the original", threads)
+		}
+	}
+}
+
+// TestInvalidZstdConfig tests that invalid levels and threads are being rejected.
+func TestInvalidZstdConfig(t *testing.T) {
+	for _, c := range []StrategyConfig{{Level: -1}, {Level: 23}, {Threads: -1}} {
+		c := c
+		_, err := newZstdAction(&c, &directory{Path: testPath}, &mockedFs{}, nil, false)
+		if err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}